@@ -0,0 +1,59 @@
+package channels
+
+import "strings"
+
+// Topics are hierarchical: tokens are separated by dots.
+// In patterns "*" matches exactly one token and ">" (allowed only as the last token)
+// matches one or more trailing tokens, e.g. "chat.*.messages" or "orders.>".
+const (
+	Separator      = "."
+	SingleWildcard = "*"
+	MultiWildcard  = ">"
+)
+
+func IsPattern(topic string) bool {
+	for _, token := range strings.Split(topic, Separator) {
+		if token == SingleWildcard || token == MultiWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPattern reports whether the multi-token wildcard appears only as the last token of the topic.
+func ValidPattern(topic string) bool {
+	tokens := strings.Split(topic, Separator)
+	for _, token := range tokens[:len(tokens)-1] {
+		if token == MultiWildcard {
+			return false
+		}
+	}
+	return true
+}
+
+// Match reports whether the topic matches the pattern,
+// a topic channel may be matched against a pattern's channel as well.
+func Match(pattern, topic string) bool {
+	if strings.HasPrefix(pattern, FromTopic("")) {
+		if !strings.HasPrefix(topic, FromTopic("")) {
+			return false
+		}
+		pattern, topic = ParseTopic(pattern), ParseTopic(topic)
+	}
+
+	patternTokens := strings.Split(pattern, Separator)
+	topicTokens := strings.Split(topic, Separator)
+
+	for i, token := range patternTokens {
+		if token == MultiWildcard && i == len(patternTokens)-1 {
+			return len(topicTokens) > i
+		}
+		if i >= len(topicTokens) {
+			return false
+		}
+		if token != SingleWildcard && token != topicTokens[i] {
+			return false
+		}
+	}
+	return len(patternTokens) == len(topicTokens)
+}
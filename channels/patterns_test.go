@@ -0,0 +1,56 @@
+package channels
+
+import "testing"
+
+func TestValidPattern(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  bool
+	}{
+		{"chat", true},
+		{"chat.*.messages", true},
+		{"orders.>", true},
+		{">", true},
+		{"a.>.b", false},
+		{">.b", false},
+	}
+	for _, test := range tests {
+		t.Run(test.topic, func(t *testing.T) {
+			if got := ValidPattern(test.topic); got != test.want {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOwner(t *testing.T) {
+	subscriptions := NewSubscriptions()
+	subscriptions.Add(false, "topic/chat.room")
+	subscriptions.Add(true, "topic/chat.*", "topic/chat.>", "topic/orders.*")
+
+	tests := []struct {
+		channel string
+		owner   string
+		pattern bool
+		ok      bool
+	}{
+		{"topic/chat.room", "topic/chat.room", false, true},
+		{"topic/chat.lobby", "topic/chat.*", true, true},
+		{"topic/chat.lobby.x", "topic/chat.>", true, true},
+		{"topic/orders.eu", "topic/orders.*", true, true},
+		{"topic/orders.eu.paid", "", true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.channel, func(t *testing.T) {
+			owner, pattern, ok := subscriptions.Owner(test.channel)
+			if owner != test.owner || pattern != test.pattern || ok != test.ok {
+				t.Fatalf("got %q %v %v, want %q %v %v", owner, pattern, ok, test.owner, test.pattern, test.ok)
+			}
+		})
+	}
+
+	subscriptions.Remove(false, "topic/chat.room")
+	if owner, _, _ := subscriptions.Owner("topic/chat.room"); owner != "topic/chat.*" {
+		t.Fatalf("got %q after removing the channel, want %q", owner, "topic/chat.*")
+	}
+}
@@ -0,0 +1,96 @@
+package channels
+
+import "sync"
+
+// Subscriptions track the channels and channel patterns of a pubsub. Brokers that receive
+// a message through every subscription matching its channel use them to report it once,
+// through the subscription that owns the channel. It's safe for concurrent use.
+type Subscriptions struct {
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	mu       sync.RWMutex
+}
+
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		channels: map[string]struct{}{},
+		patterns: map[string]struct{}{},
+		mu:       sync.RWMutex{},
+	}
+}
+
+// Add returns the channels (or patterns) that weren't subscribed before.
+func (s *Subscriptions) Add(pattern bool, names ...string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set := s.set(pattern)
+	var added []string
+	for _, name := range names {
+		if _, ok := set[name]; !ok {
+			set[name] = struct{}{}
+			added = append(added, name)
+		}
+	}
+	return added
+}
+
+// Remove returns the channels (or patterns) that were subscribed.
+func (s *Subscriptions) Remove(pattern bool, names ...string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	set := s.set(pattern)
+	var removed []string
+	for _, name := range names {
+		if _, ok := set[name]; ok {
+			delete(set, name)
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+func (s *Subscriptions) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.channels = map[string]struct{}{}
+	s.patterns = map[string]struct{}{}
+}
+
+// Patterns returns the subscribed patterns.
+func (s *Subscriptions) Patterns() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	patterns := make([]string, 0, len(s.patterns))
+	for pattern := range s.patterns {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// Owner returns the subscription that reports messages of the channel: the channel itself
+// if it's subscribed, otherwise the least matching pattern. ok is false if nothing matches.
+func (s *Subscriptions) Owner(channel string) (owner string, pattern bool, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.channels[channel]; ok {
+		return channel, false, true
+	}
+	for candidate := range s.patterns {
+		if Match(candidate, channel) && (!ok || candidate < owner) {
+			owner, ok = candidate, true
+		}
+	}
+	return owner, true, ok
+}
+
+func (s *Subscriptions) set(pattern bool) map[string]struct{} {
+	if pattern {
+		return s.patterns
+	}
+	return s.channels
+}
@@ -0,0 +1,24 @@
+package codec
+
+// Compose chains two codecs: values are encoded by first and then by second,
+// and decoded in the reverse order.
+func Compose[A, B, C any](first Codec[A, B], second Codec[B, C]) Codec[A, C] {
+	return New[A, C](
+		func(value A) (C, error) {
+			intermediate, err := first.Encoder().Convert(value)
+			if err != nil {
+				var zero C
+				return zero, err
+			}
+			return second.Encoder().Convert(intermediate)
+		},
+		func(value C) (A, error) {
+			intermediate, err := second.Decoder().Convert(value)
+			if err != nil {
+				var zero A
+				return zero, err
+			}
+			return first.Decoder().Convert(intermediate)
+		},
+	)
+}
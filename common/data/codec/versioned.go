@@ -0,0 +1,56 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported codec version")
+
+// VersionOption configures the version a versioned codec encodes with.
+type VersionOption func(version *byte)
+
+// WithVersion sets the version payloads are encoded with.
+func WithVersion(version byte) VersionOption {
+	return func(current *byte) {
+		*current = version
+	}
+}
+
+// EncodingVersion returns the version the options pick, fallback without any.
+func EncodingVersion(fallback byte, opts ...VersionOption) byte {
+	version := fallback
+	for _, opt := range opts {
+		opt(&version)
+	}
+	return version
+}
+
+// Versioned prefixes encoded payloads with the current version byte and picks the decoder
+// by the version byte of incoming payloads, so older formats stay readable during rollouts.
+func Versioned[T any](current byte, versions map[byte]Binary[T]) Binary[T] {
+	return New[T, []byte](
+		func(value T) ([]byte, error) {
+			c, ok := versions[current]
+			if !ok {
+				return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, current)
+			}
+			payload, err := c.Encoder().Convert(value)
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte{current}, payload...), nil
+		},
+		func(payload []byte) (T, error) {
+			var zero T
+			if len(payload) == 0 {
+				return zero, errors.New("empty payload")
+			}
+			c, ok := versions[payload[0]]
+			if !ok {
+				return zero, fmt.Errorf("%w: %d", ErrUnsupportedVersion, payload[0])
+			}
+			return c.Decoder().Convert(payload[1:])
+		},
+	)
+}
@@ -18,3 +18,12 @@ type Broker[T any] interface {
 	Push(ctx context.Context, channel string, message T) error
 	PubSub() PubSub[T]
 }
+
+// PatternPubSub is implemented by pubsubs that can subscribe channel patterns
+// (see channels.Match). Messages are reported with the actual channel they were pushed to,
+// once however many of the subscribed channels and patterns match it.
+type PatternPubSub[T any] interface {
+	PubSub[T]
+	PSubscribe(ctx context.Context, patterns ...string) error
+	PUnsubscribe(ctx context.Context, patterns ...string) error
+}
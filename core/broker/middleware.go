@@ -0,0 +1,90 @@
+package broker
+
+import "context"
+
+type PushFunc[T any] func(ctx context.Context, channel string, message T) error
+
+// StreamFunc transforms the stream of a pubsub's messages, e.g. to observe, filter or sample them.
+// The returned channel must be closed once the source is closed.
+type StreamFunc[T any] func(ctx context.Context, messages <-chan Message[T]) <-chan Message[T]
+
+// Middleware intercepts the pushes and the received messages of a broker, either part may be nil.
+type Middleware[T any] struct {
+	Push   func(next PushFunc[T]) PushFunc[T]
+	Stream StreamFunc[T]
+}
+
+// Chain wraps the broker with the middlewares, the first middleware is the outermost:
+// it sees pushes first and received messages last. A nil broker is returned as it is.
+func Chain[T any](broker Broker[T], middlewares ...Middleware[T]) Broker[T] {
+	if broker == nil || len(middlewares) == 0 {
+		return broker
+	}
+
+	push := broker.Push
+	var streams []StreamFunc[T]
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i].Push != nil {
+			push = middlewares[i].Push(push)
+		}
+		if middlewares[i].Stream != nil {
+			streams = append(streams, middlewares[i].Stream)
+		}
+	}
+
+	return &chainedBroker[T]{
+		base:    broker,
+		push:    push,
+		streams: streams,
+	}
+}
+
+type chainedBroker[T any] struct {
+	base Broker[T]
+	push PushFunc[T]
+	// innermost first
+	streams []StreamFunc[T]
+}
+
+func (broker *chainedBroker[T]) Push(ctx context.Context, channel string, message T) error {
+	return broker.push(ctx, channel, message)
+}
+
+// PubSub keeps pattern subscriptions available if the underlying pubsub supports them.
+func (broker *chainedBroker[T]) PubSub() PubSub[T] {
+	base := broker.base.PubSub()
+	wrapped := &chainedPubSub[T]{PubSub: base, streams: broker.streams}
+	if patterns, ok := base.(PatternPubSub[T]); ok {
+		return &chainedPatternPubSub[T]{chainedPubSub: wrapped, patterns: patterns}
+	}
+	return wrapped
+}
+
+type chainedPubSub[T any] struct {
+	PubSub[T]
+	streams []StreamFunc[T]
+}
+
+func (pubsub *chainedPubSub[T]) Channel(ctx context.Context) (<-chan Message[T], error) {
+	messages, err := pubsub.PubSub.Channel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, stream := range pubsub.streams {
+		messages = stream(ctx, messages)
+	}
+	return messages, nil
+}
+
+type chainedPatternPubSub[T any] struct {
+	*chainedPubSub[T]
+	patterns PatternPubSub[T]
+}
+
+func (pubsub *chainedPatternPubSub[T]) PSubscribe(ctx context.Context, patterns ...string) error {
+	return pubsub.patterns.PSubscribe(ctx, patterns...)
+}
+
+func (pubsub *chainedPatternPubSub[T]) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	return pubsub.patterns.PUnsubscribe(ctx, patterns...)
+}
@@ -0,0 +1,27 @@
+package deadletter
+
+import (
+	"context"
+	"sakura/core/event"
+	"time"
+)
+
+// Letter is a message that couldn't be delivered to a user.
+type Letter struct {
+	User     string
+	Topic    string
+	Event    event.Event
+	Attempts int
+	Reason   string
+	Time     time.Time
+}
+
+// Store keeps undeliverable messages for inspection and manual handling.
+type Store interface {
+	Put(ctx context.Context, letter Letter) error
+	// List returns up to limit letters of the user oldest first, an empty user lists the letters of all users.
+	// A non-positive limit means no limit.
+	List(ctx context.Context, user string, limit int) ([]Letter, error)
+	// Remove deletes the user's letters of the message, e.g. once they were handled.
+	Remove(ctx context.Context, user, messageID string) error
+}
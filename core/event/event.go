@@ -1,13 +1,91 @@
 package event
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+type Metadata struct {
+	// ID is unique and time-ordered: ids of later events compare greater as strings.
+	ID          string
+	Timestamp   time.Time
+	Publisher   string
+	ContentType string
+	Headers     map[string]string
+}
+
 type Event struct {
 	Name string
 	Data []byte
+	Metadata
 }
 
-func New(name string, data []byte) Event {
-	return Event{
+type Option func(*Event)
+
+// New creates an event with a fresh id and the current timestamp, options may override them.
+func New(name string, data []byte, options ...Option) Event {
+	now := time.Now()
+	ev := Event{
 		Name: name,
 		Data: data,
+		Metadata: Metadata{
+			ID:        NewID(now),
+			Timestamp: now,
+		},
+	}
+	for _, option := range options {
+		option(&ev)
+	}
+	return ev
+}
+
+func NewID(now time.Time) string {
+	var random [8]byte
+	// crypto/rand never fails on supported platforms
+	_, _ = rand.Read(random[:])
+	return fmt.Sprintf("%016x%016x", uint64(now.UnixNano()), binary.BigEndian.Uint64(random[:]))
+}
+
+func WithMetadata(metadata Metadata) Option {
+	return func(ev *Event) {
+		ev.Metadata = metadata
+	}
+}
+
+func WithID(id string) Option {
+	return func(ev *Event) {
+		ev.ID = id
+	}
+}
+
+func WithTimestamp(timestamp time.Time) Option {
+	return func(ev *Event) {
+		ev.Timestamp = timestamp
+	}
+}
+
+func WithPublisher(publisher string) Option {
+	return func(ev *Event) {
+		ev.Publisher = publisher
+	}
+}
+
+func WithContentType(contentType string) Option {
+	return func(ev *Event) {
+		ev.ContentType = contentType
+	}
+}
+
+func WithHeader(key, value string) Option {
+	return func(ev *Event) {
+		// the map may be shared with another event, so it's copied rather than modified
+		headers := make(map[string]string, len(ev.Headers)+1)
+		for k, v := range ev.Headers {
+			headers[k] = v
+		}
+		headers[key] = value
+		ev.Headers = headers
 	}
 }
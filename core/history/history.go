@@ -0,0 +1,28 @@
+package history
+
+import (
+	"context"
+	"sakura/core/event"
+	"time"
+)
+
+type History interface {
+	Append(ctx context.Context, topic string, ev event.Event) error
+	// Range returns up to limit events published after the event with the since id
+	// (or all retained events if since is empty), oldest first. A non-positive limit means no limit.
+	Range(ctx context.Context, topic string, since string, limit int) ([]event.Event, error)
+}
+
+// Limits bound the history of a topic, zero values mean no bound.
+type Limits struct {
+	MaxLen int
+	MaxAge time.Duration
+}
+
+type Policy func(topic string) Limits
+
+func Uniform(limits Limits) Policy {
+	return func(string) Limits {
+		return limits
+	}
+}
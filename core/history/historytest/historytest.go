@@ -0,0 +1,136 @@
+package historytest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sakura/core/event"
+	"sakura/core/history"
+	"testing"
+	"time"
+)
+
+// Open returns an empty history bounded by the policy.
+type Open func(t *testing.T, policy history.Policy) history.History
+
+// Run tests the behavior every history implementation shares.
+// Only the names, data, ids and timestamps of the events are compared.
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, open Open)
+	}{
+		{"Range", testRange},
+		{"Topics", testTopics},
+		{"MaxLen", testMaxLen},
+		{"MaxAge", testMaxAge},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, open)
+		})
+	}
+}
+
+// Events returns n events with increasing ids, published a second apart up to now.
+func Events(n int) []event.Event {
+	now := time.Now()
+	events := make([]event.Event, n)
+	for i := range events {
+		events[i] = event.New("message", []byte(fmt.Sprint(i)),
+			event.WithID(fmt.Sprintf("%032d", i+1)),
+			event.WithTimestamp(now.Add(time.Duration(i-n+1)*time.Second)),
+		)
+	}
+	return events
+}
+
+// Append appends the events to the topic's history.
+func Append(t *testing.T, h history.History, topic string, events ...event.Event) {
+	t.Helper()
+
+	for _, ev := range events {
+		if err := h.Append(context.Background(), topic, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// AssertRange checks that the range returns the events.
+func AssertRange(t *testing.T, h history.History, topic, since string, limit int, want ...event.Event) {
+	t.Helper()
+
+	got, err := h.Range(context.Background(), topic, since, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Name != want[i].Name || !bytes.Equal(got[i].Data, want[i].Data) ||
+			got[i].ID != want[i].ID || !got[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Fatalf("event %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func testRange(t *testing.T, open Open) {
+	h := open(t, history.Uniform(history.Limits{}))
+	events := Events(5)
+	Append(t, h, "chat", events...)
+
+	tests := []struct {
+		name  string
+		since string
+		limit int
+		want  []event.Event
+	}{
+		{"all", "", 0, events},
+		{"since", events[1].ID, 0, events[2:]},
+		{"since the last", events[4].ID, 0, nil},
+		{"limit", "", 2, events[:2]},
+		{"since and limit", events[1].ID, 2, events[2:4]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			AssertRange(t, h, "chat", test.since, test.limit, test.want...)
+		})
+	}
+}
+
+func testTopics(t *testing.T, open Open) {
+	h := open(t, history.Uniform(history.Limits{}))
+	events := Events(3)
+	Append(t, h, "chat", events[0], events[2])
+	Append(t, h, "news", events[1])
+
+	AssertRange(t, h, "chat", "", 0, events[0], events[2])
+	AssertRange(t, h, "news", "", 0, events[1])
+	AssertRange(t, h, "sports", "", 0)
+}
+
+func testMaxLen(t *testing.T, open Open) {
+	h := open(t, func(topic string) history.Limits {
+		if topic == "chat" {
+			return history.Limits{MaxLen: 2}
+		}
+		return history.Limits{}
+	})
+	events := Events(4)
+	Append(t, h, "chat", events...)
+	Append(t, h, "news", events...)
+
+	AssertRange(t, h, "chat", "", 0, events[2:]...)
+	AssertRange(t, h, "news", "", 0, events...)
+}
+
+func testMaxAge(t *testing.T, open Open) {
+	h := open(t, history.Uniform(history.Limits{MaxAge: time.Minute}))
+	events := Events(2)
+	events[0].Timestamp = time.Now().Add(-time.Hour)
+	Append(t, h, "chat", events...)
+
+	AssertRange(t, h, "chat", "", 0, events[1])
+}
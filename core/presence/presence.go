@@ -0,0 +1,16 @@
+package presence
+
+import (
+	"context"
+	"time"
+)
+
+// Store tracks which users are connected to which nodes.
+// Entries expire unless they are touched again within their ttl,
+// so users of a crashed node go offline on their own.
+type Store interface {
+	Touch(ctx context.Context, user, node string, ttl time.Duration) error
+	Remove(ctx context.Context, user, node string) error
+	// Online returns the users connected to at least one node, preserving the passed order.
+	Online(ctx context.Context, users ...string) ([]string, error)
+}
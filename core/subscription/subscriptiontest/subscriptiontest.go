@@ -0,0 +1,153 @@
+package subscriptiontest
+
+import (
+	"context"
+	"reflect"
+	"sakura/common/util"
+	"sakura/core/subscription"
+	"sort"
+	"testing"
+)
+
+// Open returns an empty storage.
+type Open func(t *testing.T) subscription.Storage
+
+var (
+	AliceNews   = subscription.Subscription{User: "alice", Topic: "news"}
+	AliceSports = subscription.Subscription{User: "alice", Topic: "sports"}
+	BobNews     = subscription.Subscription{User: "bob", Topic: "news"}
+)
+
+// Run tests the behavior every subscription storage shares.
+func Run(t *testing.T, open Open) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, open Open)
+	}{
+		{"Select", testSelect},
+		{"InsertIgnoresDuplicates", testInsertIgnoresDuplicates},
+		{"IterStops", testIterStops},
+		{"Erase", testErase},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, open)
+		})
+	}
+}
+
+// Collect returns the selected subscriptions sorted by id.
+func Collect(t *testing.T, storage subscription.Storage, selector subscription.Selector) []subscription.Subscription {
+	t.Helper()
+
+	set, err := storage.Select(context.Background(), selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subs []subscription.Subscription
+	set.Iter(context.Background(), func(sub subscription.Subscription) bool {
+		subs = append(subs, sub)
+		return true
+	})
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID() < subs[j].ID() })
+	return subs
+}
+
+func Insert(t *testing.T, storage subscription.Storage, subs ...subscription.Subscription) {
+	t.Helper()
+
+	for _, sub := range subs {
+		if err := storage.Insert(context.Background(), sub); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Erase(t *testing.T, storage subscription.Storage, selector subscription.Selector) {
+	t.Helper()
+
+	set, err := storage.Select(context.Background(), selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Erase(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSelect(t *testing.T, open Open) {
+	storage := open(t)
+	Insert(t, storage, AliceNews, AliceSports, BobNews)
+
+	tests := []struct {
+		name     string
+		selector subscription.Selector
+		want     []subscription.Subscription
+	}{
+		{"all", subscription.Selector{}, []subscription.Subscription{AliceNews, AliceSports, BobNews}},
+		{"user", subscription.Selector{User: util.MakePtr("alice")}, []subscription.Subscription{AliceNews, AliceSports}},
+		{"topic", subscription.Selector{Topic: util.MakePtr("news")}, []subscription.Subscription{AliceNews, BobNews}},
+		{"user and topic", subscription.Selector{User: util.MakePtr("bob"), Topic: util.MakePtr("news")}, []subscription.Subscription{BobNews}},
+		{"missing", subscription.Selector{User: util.MakePtr("bob"), Topic: util.MakePtr("sports")}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Collect(t, storage, test.selector); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func testInsertIgnoresDuplicates(t *testing.T, open Open) {
+	storage := open(t)
+	Insert(t, storage, AliceNews, AliceNews)
+
+	want := []subscription.Subscription{AliceNews}
+	if got := Collect(t, storage, subscription.Selector{}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func testIterStops(t *testing.T, open Open) {
+	storage := open(t)
+	Insert(t, storage, AliceNews, AliceSports, BobNews)
+
+	set, err := storage.Select(context.Background(), subscription.Selector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int
+	set.Iter(context.Background(), func(subscription.Subscription) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Fatalf("got %d calls, want 1", calls)
+	}
+}
+
+func testErase(t *testing.T, open Open) {
+	tests := []struct {
+		name     string
+		selector subscription.Selector
+		want     []subscription.Subscription
+	}{
+		{"all", subscription.Selector{}, nil},
+		{"user", subscription.Selector{User: util.MakePtr("alice")}, []subscription.Subscription{BobNews}},
+		{"topic", subscription.Selector{Topic: util.MakePtr("news")}, []subscription.Subscription{AliceSports}},
+		{"user and topic", subscription.Selector{User: util.MakePtr("alice"), Topic: util.MakePtr("news")}, []subscription.Subscription{AliceSports, BobNews}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := open(t)
+			Insert(t, storage, AliceNews, AliceSports, BobNews)
+
+			Erase(t, storage, test.selector)
+			if got := Collect(t, storage, subscription.Selector{}); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -6,4 +6,7 @@ const (
 	UnsubscribeEvent    = "unsubscribe"
 	UnsubscribeAllEvent = "unsubscribe-all"
 	TopicErasureEvent   = "topic-erasure"
+	JoinEvent           = "join"
+	LeaveEvent          = "leave"
+	DirectMessageEvent  = "direct-message"
 )
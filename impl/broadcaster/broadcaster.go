@@ -2,65 +2,318 @@ package broadcaster
 
 import (
 	"context"
-	"github.com/samber/lo"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
 	"log"
 	"sakura"
 	"sakura/channels"
+	"sort"
+	"sync"
 )
 
+var ErrConnectionLimit = errors.New("connection limit exceeded")
+
 type Broadcaster struct {
 	sakura        *sakura.Sakura
 	subscriptions *SubscriptionManager
 	users         *UserManager
+	channels      *ChannelManager
 	pubsub        sakura.PubSub
+	parked        *parkingLot
+	config        config
+
+	// missingIDs logs once that the broker's codec drops the event metadata.
+	missingIDs sync.Once
+
+	// lifecycle serializes connects and disconnects,
+	// so the first connection and the last disconnection of a user never interleave.
+	// It's never held across pushes or history reads and the event loop never takes it.
+	lifecycle sync.Mutex
+	// membership makes changes of the tracked subscriptions atomic with users going online and offline,
+	// it only guards in-memory state and channel subscriptions, so the event loop may take it
+	membership sync.Mutex
 }
 
-func New(sakura *sakura.Sakura) *Broadcaster {
+func New(sakura *sakura.Sakura, opts ...Option) *Broadcaster {
+	cfg := config{
+		queueSize:      DefaultQueueSize,
+		overflowPolicy: Drop,
+		replayLimit:    DefaultReplayLimit,
+		presenceTTL:    DefaultPresenceTTL,
+
+		maxAttempts:       DefaultMaxAttempts,
+		redeliveryBackoff: DefaultRedeliveryBackoff,
+		pendingTTL:        DefaultPendingTTL,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.nodeID == "" {
+		// crypto/rand never fails on supported platforms
+		cfg.nodeID, _ = newID()
+	}
+
+	pubsub := sakura.Broker().PubSub()
 	return &Broadcaster{
 		sakura:        sakura,
 		subscriptions: newSubscriptionManager(),
 		users:         newUserManager(),
-		pubsub:        sakura.Broker().PubSub(),
+		channels:      newChannelManager(pubsub),
+		pubsub:        pubsub,
+		parked:        newParkingLot(),
+		config:        cfg,
 	}
 }
 
+func (broadcaster *Broadcaster) Sakura() *sakura.Sakura {
+	return broadcaster.sakura
+}
+
 func (broadcaster *Broadcaster) Run(ctx context.Context) error {
+	if broadcaster.sakura.Presence() != nil {
+		go broadcaster.heartbeat(ctx)
+	}
+	if broadcaster.config.reliable != nil {
+		go broadcaster.redeliver(ctx)
+	}
 	return broadcaster.processEvents(ctx)
 }
 
-func (broadcaster *Broadcaster) Connect(ctx context.Context, connection User) error {
-	user := broadcaster.sakura.User(connection.ID())
+// Connect registers a connection of the user and returns its id.
+// Users may hold several connections at once, each of them receives every delivery.
+func (broadcaster *Broadcaster) Connect(ctx context.Context, connection User, options ...ConnectOption) (string, error) {
+	var connectCfg connectConfig
+	for _, option := range options {
+		option(&connectCfg)
+	}
 
-	var newTopics []string
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	userID := connection.ID()
+	conn := newConnection(id, connection, broadcaster.config)
+	conn.withoutAcks = connectCfg.withoutAcks
 
-	newTopics = append(newTopics, user.Channel())
+	broadcaster.lifecycle.Lock()
 
-	subs, err := user.Subscriptions(ctx)
+	var evicted []string
+	if limit := broadcaster.config.connectionLimit; limit > 0 {
+		existing := broadcaster.users.Connections(userID)
+		if len(existing) >= limit {
+			if broadcaster.config.evictionPolicy != EvictOldest {
+				broadcaster.lifecycle.Unlock()
+				return "", ErrConnectionLimit
+			}
+			evicted = existing[:len(existing)-limit+1]
+		}
+	}
+
+	first, subs, err := broadcaster.register(ctx, conn)
 	if err != nil {
-		return err
+		broadcaster.lifecycle.Unlock()
+		return "", err
 	}
 
-	newTopics = append(
-		newTopics,
-		lo.Map(subs, func(sub string, _ int) string { return broadcaster.sakura.Topic(sub).Channel() })...,
-	)
+	evictedConns := broadcaster.evict(evicted)
 
-	if err := broadcaster.pubsub.Subscribe(ctx, newTopics...); err != nil {
-		return err
+	broadcaster.lifecycle.Unlock()
+
+	// announcing the presence pushes to the broker, which may wait for the event loop
+	if first {
+		broadcaster.join(ctx, userID, subs)
 	}
-	broadcaster.users.Add(connection)
-	for _, sub := range subs {
-		broadcaster.subscriptions.Add(sub, connection.ID())
+	// the user's other connections have their own copies of the evicted ones' unacked messages
+	for _, evictedConn := range evictedConns {
+		broadcaster.close(evictedConn)
 	}
-	return nil
+	broadcaster.resume(conn)
+	if connectCfg.lastSeen != "" {
+		broadcaster.replay(ctx, conn, connectCfg.lastSeen)
+	}
+	go conn.run()
+
+	return id, nil
 }
 
+// Disconnect removes the connection, disconnecting an unknown connection is a no-op.
 func (broadcaster *Broadcaster) Disconnect(ctx context.Context, id string) error {
-	broadcaster.users.Delete(id)
-	broadcaster.subscriptions.RemoveByUser(id)
+	return broadcaster.disconnect(ctx, id, false)
+}
+
+// disconnect removes the connection, kicking also closes the underlying transport.
+func (broadcaster *Broadcaster) disconnect(ctx context.Context, id string, kick bool) error {
+	broadcaster.lifecycle.Lock()
+	conn, topics, last, err := broadcaster.detach(ctx, id)
+	broadcaster.lifecycle.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	if kick {
+		broadcaster.close(conn)
+	} else {
+		conn.close()
+	}
+	// the user's other connections have their own copies of the unacked messages
+	if last {
+		broadcaster.abandon(conn)
+		broadcaster.leave(ctx, conn.user.ID(), topics)
+	}
+	return err
+}
+
+// evict removes the connections, they're never the last ones of their users.
+func (broadcaster *Broadcaster) evict(ids []string) []*connection {
+	var conns []*connection
+	for _, id := range ids {
+		if conn, _, ok := broadcaster.users.Delete(id); ok {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
+
+func (broadcaster *Broadcaster) Metrics() map[string]ConnectionMetrics {
+	metrics := map[string]ConnectionMetrics{}
+	broadcaster.users.Iter(func(conn *connection) {
+		metrics[conn.id] = conn.metrics()
+	})
+	return metrics
+}
+
+// register adds the connection. The first connection of a user also loads the user's subscriptions
+// and subscribes the channels the user needs, the loaded subscriptions are returned then.
+func (broadcaster *Broadcaster) register(ctx context.Context, conn *connection) (bool, []string, error) {
+	user := broadcaster.sakura.User(conn.user.ID())
+
+	broadcaster.membership.Lock()
+	first := broadcaster.users.Add(conn)
+	var err error
+	if first {
+		// the user's channel is subscribed before the stored subscriptions are loaded,
+		// so subscriptions made in the meantime arrive as events
+		err = broadcaster.channels.Acquire(ctx, user.Channel())
+	}
+	broadcaster.membership.Unlock()
+
+	if !first {
+		return false, nil, nil
+	}
+	if err == nil {
+		var subs []string
+		subs, err = user.Subscriptions(ctx)
+		if err == nil {
+			err = broadcaster.follow(ctx, user.ID(), subs)
+		}
+		if err == nil {
+			return true, subs, nil
+		}
+	}
+
+	if _, _, _, detachErr := broadcaster.detach(ctx, conn.id); detachErr != nil {
+		log.Println("failed to unsubscribe channels:", detachErr)
+	}
+	return false, nil, err
+}
+
+// follow tracks the subscriptions of an online user and subscribes their channels.
+func (broadcaster *Broadcaster) follow(ctx context.Context, userID string, subs []string) error {
+	broadcaster.membership.Lock()
+	defer broadcaster.membership.Unlock()
+
+	var added []string
+	for _, sub := range subs {
+		if broadcaster.subscriptions.Add(sub, userID) {
+			added = append(added, sub)
+		}
+	}
+
+	topicChannels, topicPatterns := splitTopics(added)
+	if err := broadcaster.channels.Acquire(ctx, topicChannels...); err != nil {
+		for _, topic := range added {
+			broadcaster.subscriptions.Remove(topic, userID)
+		}
+		return err
+	}
+	err := broadcaster.channels.AcquirePatterns(ctx, topicPatterns...)
+	if errors.Is(err, ErrPatternsUnsupported) {
+		// the stored patterns can't be followed with this broker, plain topics still work
+		log.Println("skipping pattern subscriptions:", err)
+		broadcaster.forget(userID, added)
+		return nil
+	}
+	if err != nil {
+		for _, topic := range added {
+			broadcaster.subscriptions.Remove(topic, userID)
+		}
+		if err := broadcaster.channels.Release(ctx, topicChannels...); err != nil {
+			log.Println("failed to unsubscribe channels:", err)
+		}
+		return err
+	}
 	return nil
 }
 
+// detach removes the connection. If it was the last connection of its user, the user's subscriptions
+// are dropped and their channels unsubscribed, the dropped topics are returned with last set.
+// Unknown connections are returned as nil.
+func (broadcaster *Broadcaster) detach(ctx context.Context, id string) (conn *connection, topics []string, last bool, err error) {
+	broadcaster.membership.Lock()
+	defer broadcaster.membership.Unlock()
+
+	conn, last, ok := broadcaster.users.Delete(id)
+	if !ok || !last {
+		return conn, nil, false, nil
+	}
+
+	userID := conn.user.ID()
+	topics = broadcaster.subscriptions.RemoveByUser(userID)
+	topicChannels, topicPatterns := splitTopics(topics)
+	err = broadcaster.channels.ReleasePatterns(ctx, topicPatterns...)
+	if releaseErr := broadcaster.channels.Release(ctx, append(topicChannels, channels.FromUser(userID))...); err == nil {
+		err = releaseErr
+	}
+	return conn, topics, true, err
+}
+
+// replay queues messages the connection missed since the last seen one.
+// It runs after the connection starts receiving live messages, so nothing falls in between.
+func (broadcaster *Broadcaster) replay(ctx context.Context, conn *connection, lastSeen string) {
+	userID := conn.user.ID()
+
+	subs, err := broadcaster.sakura.User(userID).Subscriptions(ctx)
+	if err != nil {
+		log.Println("failed to load subscriptions for a replay:", err)
+		return
+	}
+
+	var missed []Envelope
+	for _, topic := range subs {
+		// patterns have no history of their own
+		if channels.IsPattern(topic) {
+			continue
+		}
+		events, err := broadcaster.sakura.Topic(topic).History(ctx, lastSeen, broadcaster.config.replayLimit)
+		if errors.Is(err, sakura.ErrHistoryDisabled) {
+			return
+		}
+		if err != nil {
+			log.Println("failed to load the history of a topic:", err)
+			continue
+		}
+		for _, ev := range events {
+			missed = append(missed, broadcaster.track(conn, newEnvelope(topic, ev)))
+		}
+	}
+
+	// ids are time-ordered, so this restores the publication order across topics
+	sort.SliceStable(missed, func(i, j int) bool { return missed[i].MessageID < missed[j].MessageID })
+	conn.prepend(missed)
+}
+
 func (broadcaster *Broadcaster) processEvents(ctx context.Context) error {
 	channel, err := broadcaster.pubsub.Channel(ctx)
 	if err != nil {
@@ -72,24 +325,33 @@ func (broadcaster *Broadcaster) processEvents(ctx context.Context) error {
 		case sakura.SubscribeEvent:
 			user := channels.ParseUser(message.Channel)
 			topic := string(message.Data.Data)
-			broadcaster.subscriptions.Add(user, topic)
+			broadcaster.subscribe(ctx, user, topic)
 		case sakura.UnsubscribeEvent:
 			user := channels.ParseUser(message.Channel)
 			topic := string(message.Data.Data)
-			broadcaster.subscriptions.Remove(user, topic)
+			broadcaster.unsubscribe(ctx, user, topic)
 		case sakura.UnsubscribeAllEvent:
 			user := channels.ParseUser(message.Channel)
-			broadcaster.subscriptions.RemoveByUser(user)
+			broadcaster.unsubscribeAll(ctx, user)
+		case sakura.DirectMessageEvent:
+			user := channels.ParseUser(message.Channel)
+			for _, conn := range broadcaster.users.Get(user) {
+				broadcaster.deliver(conn, newEnvelope("", message.Data))
+			}
 		case sakura.TopicErasureEvent:
 			topic := channels.ParseTopic(message.Channel)
-			broadcaster.subscriptions.RemoveByTopic(topic)
-		case sakura.PublishEvent:
+			broadcaster.erase(ctx, topic)
+		case sakura.PublishEvent, sakura.JoinEvent, sakura.LeaveEvent:
+			if message.Data.ID == "" {
+				broadcaster.missingIDs.Do(func() {
+					log.Println("events arrive without ids, so clients can't resume from them:" +
+						" encode with the codec's latest version once every node decodes it")
+				})
+			}
 			topic := channels.ParseTopic(message.Channel)
 			broadcaster.subscriptions.Iter(topic, func(userID string) {
-				if user, ok := broadcaster.users.Get(userID); ok {
-					if err := user.Send(ctx, message.Data.Data); err != nil {
-						log.Println("failed to send data:", err)
-					}
+				for _, conn := range broadcaster.users.Get(userID) {
+					broadcaster.deliver(conn, broadcaster.track(conn, newEnvelope(topic, message.Data)))
 				}
 			})
 		}
@@ -97,3 +359,124 @@ func (broadcaster *Broadcaster) processEvents(ctx context.Context) error {
 
 	return nil
 }
+
+func (broadcaster *Broadcaster) deliver(conn *connection, envelope Envelope) {
+	if conn.push(envelope) {
+		return
+	}
+
+	// disconnecting takes the lifecycle lock, the event loop must not wait for it
+	go func() {
+		if err := broadcaster.disconnect(context.Background(), conn.id, true); err != nil {
+			log.Println("failed to disconnect a slow connection:", err)
+		}
+	}()
+}
+
+// close stops the connection's writer and closes the underlying transport if it supports that.
+func (broadcaster *Broadcaster) close(conn *connection) {
+	conn.close()
+	if closer, ok := conn.user.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Println("failed to close a connection:", err)
+		}
+	}
+}
+
+func (broadcaster *Broadcaster) subscribe(ctx context.Context, user, topic string) {
+	broadcaster.membership.Lock()
+	defer broadcaster.membership.Unlock()
+
+	// the event may outrun a disconnect, so only users that are still connected are tracked
+	if !broadcaster.users.Online(user) {
+		return
+	}
+	if !broadcaster.subscriptions.Add(topic, user) {
+		return
+	}
+	if err := broadcaster.acquire(ctx, topic); errors.Is(err, ErrPatternsUnsupported) {
+		broadcaster.forget(user, []string{topic})
+	}
+}
+
+func (broadcaster *Broadcaster) unsubscribe(ctx context.Context, user, topic string) {
+	broadcaster.membership.Lock()
+	defer broadcaster.membership.Unlock()
+
+	if broadcaster.subscriptions.Remove(topic, user) {
+		broadcaster.release(ctx, topic)
+	}
+}
+
+func (broadcaster *Broadcaster) unsubscribeAll(ctx context.Context, user string) {
+	broadcaster.membership.Lock()
+	defer broadcaster.membership.Unlock()
+
+	broadcaster.release(ctx, broadcaster.subscriptions.RemoveByUser(user)...)
+}
+
+// erase drops all local subscriptions to the topic.
+func (broadcaster *Broadcaster) erase(ctx context.Context, topic string) {
+	broadcaster.membership.Lock()
+	defer broadcaster.membership.Unlock()
+
+	var staleTopics []string
+	for range broadcaster.subscriptions.RemoveByTopic(topic) {
+		staleTopics = append(staleTopics, topic)
+	}
+	broadcaster.release(ctx, staleTopics...)
+}
+
+// acquire subscribes the channels of the topics, patterns are subscribed as channel patterns.
+// Failures are logged, the error of subscribing the patterns is returned.
+func (broadcaster *Broadcaster) acquire(ctx context.Context, topics ...string) error {
+	topicChannels, topicPatterns := splitTopics(topics)
+	if err := broadcaster.channels.Acquire(ctx, topicChannels...); err != nil {
+		log.Println("failed to subscribe channels:", err)
+	}
+	err := broadcaster.channels.AcquirePatterns(ctx, topicPatterns...)
+	if err != nil {
+		log.Println("failed to subscribe channel patterns:", err)
+	}
+	return err
+}
+
+// forget stops tracking the user's pattern subscriptions among the topics,
+// they're kept in the storage and followed again once the broker supports patterns.
+func (broadcaster *Broadcaster) forget(userID string, topics []string) {
+	for _, topic := range topics {
+		if channels.IsPattern(topic) {
+			broadcaster.subscriptions.Remove(topic, userID)
+		}
+	}
+}
+
+func (broadcaster *Broadcaster) release(ctx context.Context, topics ...string) {
+	topicChannels, topicPatterns := splitTopics(topics)
+	if err := broadcaster.channels.Release(ctx, topicChannels...); err != nil {
+		log.Println("failed to unsubscribe channels:", err)
+	}
+	if err := broadcaster.channels.ReleasePatterns(ctx, topicPatterns...); err != nil {
+		log.Println("failed to unsubscribe channel patterns:", err)
+	}
+}
+
+// splitTopics returns the channels of plain topics and the channel patterns of pattern topics.
+func splitTopics(topics []string) (topicChannels, topicPatterns []string) {
+	for _, topic := range topics {
+		if channels.IsPattern(topic) {
+			topicPatterns = append(topicPatterns, channels.FromTopic(topic))
+		} else {
+			topicChannels = append(topicChannels, channels.FromTopic(topic))
+		}
+	}
+	return topicChannels, topicPatterns
+}
+
+func newID() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
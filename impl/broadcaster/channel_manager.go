@@ -0,0 +1,139 @@
+package broadcaster
+
+import (
+	"context"
+	"errors"
+	"sakura"
+	"sakura/core/broker"
+	"sakura/core/event"
+	"sync"
+)
+
+var ErrPatternsUnsupported = errors.New("the broker doesn't support pattern subscriptions")
+
+type channelKey struct {
+	name    string
+	pattern bool
+}
+
+// ChannelManager keeps the node's pubsub subscribed to a channel (or a channel pattern)
+// as long as there is at least one local reference to it.
+type ChannelManager struct {
+	pubsub sakura.PubSub
+	refs   map[channelKey]int
+	mu     sync.Mutex
+}
+
+func newChannelManager(pubsub sakura.PubSub) *ChannelManager {
+	return &ChannelManager{
+		pubsub: pubsub,
+		refs:   map[channelKey]int{},
+		mu:     sync.Mutex{},
+	}
+}
+
+// Acquire takes a reference per passed channel (repeated channels take several references)
+// and subscribes the channels that had no references before.
+func (manager *ChannelManager) Acquire(ctx context.Context, channels ...string) error {
+	return manager.acquire(ctx, false, channels)
+}
+
+// Release drops a reference per passed channel and unsubscribes the channels left without references.
+func (manager *ChannelManager) Release(ctx context.Context, channels ...string) error {
+	return manager.release(ctx, false, channels)
+}
+
+// AcquirePatterns is Acquire for channel patterns, the broker's pubsub must implement broker.PatternPubSub.
+func (manager *ChannelManager) AcquirePatterns(ctx context.Context, patterns ...string) error {
+	return manager.acquire(ctx, true, patterns)
+}
+
+func (manager *ChannelManager) ReleasePatterns(ctx context.Context, patterns ...string) error {
+	return manager.release(ctx, true, patterns)
+}
+
+func (manager *ChannelManager) acquire(ctx context.Context, pattern bool, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	var fresh []string
+	for _, name := range names {
+		key := channelKey{name: name, pattern: pattern}
+		if manager.refs[key] == 0 {
+			fresh = append(fresh, name)
+		}
+		manager.refs[key]++
+	}
+
+	if err := manager.subscribe(ctx, pattern, fresh); err != nil {
+		for _, name := range names {
+			manager.decrement(channelKey{name: name, pattern: pattern})
+		}
+		return err
+	}
+	return nil
+}
+
+func (manager *ChannelManager) release(ctx context.Context, pattern bool, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	var stale []string
+	for _, name := range names {
+		if manager.decrement(channelKey{name: name, pattern: pattern}) {
+			stale = append(stale, name)
+		}
+	}
+
+	return manager.unsubscribe(ctx, pattern, stale)
+}
+
+func (manager *ChannelManager) subscribe(ctx context.Context, pattern bool, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if !pattern {
+		return manager.pubsub.Subscribe(ctx, names...)
+	}
+	pubsub, ok := manager.pubsub.(broker.PatternPubSub[event.Event])
+	if !ok {
+		return ErrPatternsUnsupported
+	}
+	return pubsub.PSubscribe(ctx, names...)
+}
+
+func (manager *ChannelManager) unsubscribe(ctx context.Context, pattern bool, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	if !pattern {
+		return manager.pubsub.Unsubscribe(ctx, names...)
+	}
+	pubsub, ok := manager.pubsub.(broker.PatternPubSub[event.Event])
+	if !ok {
+		return ErrPatternsUnsupported
+	}
+	return pubsub.PUnsubscribe(ctx, names...)
+}
+
+// decrement reports whether the last reference to the channel was dropped.
+func (manager *ChannelManager) decrement(key channelKey) bool {
+	refs, ok := manager.refs[key]
+	if !ok {
+		return false
+	}
+	if refs <= 1 {
+		delete(manager.refs, key)
+		return true
+	}
+	manager.refs[key] = refs - 1
+	return false
+}
@@ -0,0 +1,193 @@
+package broadcaster
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+type ConnectionMetrics struct {
+	User       string
+	QueueDepth int
+	Sent       uint64
+	Failed     uint64
+	Dropped    uint64
+	Coalesced  uint64
+}
+
+// connection owns a bounded outbound queue drained by its own writer goroutine,
+// so a slow connection never stalls deliveries to the others.
+type connection struct {
+	id     string
+	user   User
+	config config
+
+	queue  []Envelope
+	signal chan struct{}
+	// unacked deliveries of reliable topics by message id
+	pending map[string]*pendingDelivery
+	// withoutAcks connections get reliable topics at most once, see WithoutAcks
+	withoutAcks bool
+	mu          sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sent      atomic.Uint64
+	failed    atomic.Uint64
+	dropped   atomic.Uint64
+	coalesced atomic.Uint64
+}
+
+func newConnection(id string, user User, cfg config) *connection {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &connection{
+		id:      id,
+		user:    user,
+		config:  cfg,
+		signal:  make(chan struct{}, 1),
+		pending: map[string]*pendingDelivery{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (conn *connection) run() {
+	for {
+		select {
+		case <-conn.ctx.Done():
+			return
+		case <-conn.signal:
+		}
+
+		for {
+			item, ok := conn.pop()
+			if !ok {
+				break
+			}
+			if err := conn.send(item); err != nil {
+				conn.failed.Add(1)
+				log.Println("failed to send data:", err)
+				continue
+			}
+			conn.sent.Add(1)
+		}
+	}
+}
+
+func (conn *connection) send(envelope Envelope) error {
+	if user, ok := conn.user.(EnvelopeUser); ok {
+		return user.SendEnvelope(conn.ctx, envelope)
+	}
+	return conn.user.Send(conn.ctx, envelope.Data)
+}
+
+// push reports false if the connection overflowed and must be disconnected.
+func (conn *connection) push(item Envelope) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.ctx.Err() != nil {
+		return true
+	}
+
+	if len(conn.queue) >= conn.config.queueSize {
+		switch conn.config.overflowPolicy {
+		case Disconnect:
+			return false
+		case Coalesce:
+			conn.coalesce(item)
+		default:
+			conn.dropped.Add(1)
+		}
+		return true
+	}
+
+	conn.queue = append(conn.queue, item)
+	select {
+	case conn.signal <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// coalesce replaces the queued envelope of the same topic and event with the new one,
+// if there is none the oldest envelope gives way to it.
+// Direct messages have no topic and never replace each other.
+func (conn *connection) coalesce(item Envelope) {
+	for i := len(conn.queue) - 1; i >= 0 && item.Topic != ""; i-- {
+		if conn.queue[i].Topic == item.Topic && conn.queue[i].Event == item.Event {
+			conn.coalesced.Add(1)
+			conn.queue = append(conn.queue[:i], conn.queue[i+1:]...)
+			conn.queue = append(conn.queue, item)
+			return
+		}
+	}
+	conn.dropped.Add(1)
+	if len(conn.queue) > 0 {
+		conn.queue = append(conn.queue[1:], item)
+	}
+}
+
+// prepend puts the envelopes ahead of the queued ones skipping those that are already queued,
+// the queue may temporarily exceed its capacity.
+func (conn *connection) prepend(envelopes []Envelope) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	queued := map[string]struct{}{}
+	for _, envelope := range conn.queue {
+		queued[envelope.MessageID] = struct{}{}
+	}
+
+	fresh := make([]Envelope, 0, len(envelopes)+len(conn.queue))
+	for _, envelope := range envelopes {
+		if _, ok := queued[envelope.MessageID]; !ok {
+			fresh = append(fresh, envelope)
+		}
+	}
+	conn.queue = append(fresh, conn.queue...)
+
+	select {
+	case conn.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (conn *connection) pop() (Envelope, bool) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if len(conn.queue) == 0 {
+		return Envelope{}, false
+	}
+	item := conn.queue[0]
+	conn.queue[0] = Envelope{}
+	conn.queue = conn.queue[1:]
+	return item, true
+}
+
+// close stops the writer, undelivered items are discarded.
+func (conn *connection) close() {
+	conn.cancel()
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.queue = nil
+}
+
+func (conn *connection) metrics() ConnectionMetrics {
+	conn.mu.Lock()
+	depth := len(conn.queue)
+	conn.mu.Unlock()
+
+	return ConnectionMetrics{
+		User:       conn.user.ID(),
+		QueueDepth: depth,
+		Sent:       conn.sent.Load(),
+		Failed:     conn.failed.Load(),
+		Dropped:    conn.dropped.Load(),
+		Coalesced:  conn.coalesced.Load(),
+	}
+}
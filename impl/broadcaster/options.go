@@ -0,0 +1,170 @@
+package broadcaster
+
+import (
+	"sakura/core/deadletter"
+	"time"
+)
+
+// EvictionPolicy decides what happens when a user exceeds the connection limit.
+type EvictionPolicy int
+
+const (
+	// RejectNew makes Connect fail with ErrConnectionLimit.
+	RejectNew EvictionPolicy = iota
+	// EvictOldest disconnects the oldest connections of the user to make room for the new one.
+	EvictOldest
+)
+
+type config struct {
+	connectionLimit int
+	evictionPolicy  EvictionPolicy
+	queueSize       int
+	overflowPolicy  OverflowPolicy
+	replayLimit     int
+	nodeID          string
+	presenceTTL     time.Duration
+
+	reliable          func(topic string) bool
+	maxAttempts       int
+	redeliveryBackoff time.Duration
+	pendingTTL        time.Duration
+	deadLetters       deadletter.Store
+}
+
+type Option func(*config)
+
+// WithConnectionLimit limits the number of simultaneous connections per user on this node.
+// A non-positive limit means no limit.
+func WithConnectionLimit(limit int, policy EvictionPolicy) Option {
+	return func(c *config) {
+		c.connectionLimit = limit
+		c.evictionPolicy = policy
+	}
+}
+
+// OverflowPolicy decides what happens when a connection's outbound queue is full.
+type OverflowPolicy int
+
+const (
+	// Drop discards the new delivery.
+	Drop OverflowPolicy = iota
+	// Coalesce replaces the queued delivery of the same topic and event with the new one,
+	// or discards the oldest queued delivery if there is none (or the new one is a direct message).
+	Coalesce
+	// Disconnect disconnects the slow connection.
+	Disconnect
+)
+
+const DefaultQueueSize = 256
+
+// WithQueue sets the capacity of every connection's outbound queue and the overflow policy,
+// sizes below 1 keep the default capacity.
+func WithQueue(size int, policy OverflowPolicy) Option {
+	return func(c *config) {
+		if size >= 1 {
+			c.queueSize = size
+		}
+		c.overflowPolicy = policy
+	}
+}
+
+const DefaultReplayLimit = 100
+
+// WithReplayLimit limits how many missed messages per topic are replayed to a resumed connection.
+func WithReplayLimit(limit int) Option {
+	return func(c *config) {
+		c.replayLimit = limit
+	}
+}
+
+const DefaultPresenceTTL = 30 * time.Second
+
+// WithNodeID sets the id the node is registered under in the presence store,
+// a random one is used by default.
+func WithNodeID(id string) Option {
+	return func(c *config) {
+		c.nodeID = id
+	}
+}
+
+// WithPresenceTTL sets how long the presence of the node's users lasts without a heartbeat,
+// heartbeats are sent three times per ttl. Ttls under a millisecond keep the default.
+func WithPresenceTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		if ttl >= time.Millisecond {
+			c.presenceTTL = ttl
+		}
+	}
+}
+
+// WithReliableTopics turns on at-least-once delivery for the topics selected by the predicate.
+// Their messages are delivered with Envelope.Ack set and must be acked through Broadcaster.Ack,
+// otherwise they're redelivered until they run out of attempts (see WithRedelivery).
+func WithReliableTopics(reliable func(topic string) bool) Option {
+	return func(c *config) {
+		c.reliable = reliable
+	}
+}
+
+const (
+	DefaultMaxAttempts       = 5
+	DefaultRedeliveryBackoff = time.Second
+	DefaultPendingTTL        = time.Minute
+)
+
+// WithRedelivery sets how many times an unacked message is delivered to a connection
+// and how long the first redelivery waits, every next one waits twice as long, up to an hour.
+// Fewer than one attempt or a backoff under a millisecond keep the defaults.
+func WithRedelivery(maxAttempts int, backoff time.Duration) Option {
+	return func(c *config) {
+		if maxAttempts >= 1 {
+			c.maxAttempts = maxAttempts
+		}
+		if backoff >= time.Millisecond {
+			c.redeliveryBackoff = backoff
+		}
+	}
+}
+
+// WithPendingTTL sets how long the unacked messages of a closed connection wait for the user
+// to connect again, the next connection receives them as their next attempt.
+// Non-positive ttls keep the default.
+func WithPendingTTL(ttl time.Duration) Option {
+	return func(c *config) {
+		if ttl > 0 {
+			c.pendingTTL = ttl
+		}
+	}
+}
+
+// WithDeadLetters sets the store for reliable messages that were never acked,
+// without it such messages are only logged.
+func WithDeadLetters(store deadletter.Store) Option {
+	return func(c *config) {
+		c.deadLetters = store
+	}
+}
+
+type connectConfig struct {
+	lastSeen    string
+	withoutAcks bool
+}
+
+type ConnectOption func(*connectConfig)
+
+// WithLastSeen resumes a connection: messages of the user's topics published after
+// the message with the given id are replayed from the history before live ones.
+func WithLastSeen(id string) ConnectOption {
+	return func(c *connectConfig) {
+		c.lastSeen = id
+	}
+}
+
+// WithoutAcks connects a transport that can't ack messages. Reliable topics are delivered to it
+// at most once, without Envelope.Ack, and the connection doesn't take over the messages
+// parked for its user, they wait for a connection that acks.
+func WithoutAcks() ConnectOption {
+	return func(c *connectConfig) {
+		c.withoutAcks = true
+	}
+}
@@ -0,0 +1,79 @@
+package broadcaster
+
+import (
+	"sakura/channels"
+	"strings"
+)
+
+// patternTrie indexes topic patterns by their tokens,
+// so matching a topic doesn't have to test every stored pattern.
+type patternTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	// pattern is set if a stored pattern ends at this node
+	pattern string
+}
+
+func newPatternTrie() *patternTrie {
+	return &patternTrie{root: newTrieNode()}
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{children: map[string]*trieNode{}}
+}
+
+func (trie *patternTrie) Insert(pattern string) {
+	node := trie.root
+	for _, token := range strings.Split(pattern, channels.Separator) {
+		child, ok := node.children[token]
+		if !ok {
+			child = newTrieNode()
+			node.children[token] = child
+		}
+		node = child
+	}
+	node.pattern = pattern
+}
+
+func (trie *patternTrie) Remove(pattern string) {
+	trie.remove(trie.root, strings.Split(pattern, channels.Separator))
+}
+
+// remove reports whether the node became empty and can be pruned.
+func (trie *patternTrie) remove(node *trieNode, tokens []string) bool {
+	if len(tokens) == 0 {
+		node.pattern = ""
+	} else if child, ok := node.children[tokens[0]]; ok && trie.remove(child, tokens[1:]) {
+		delete(node.children, tokens[0])
+	}
+	return node.pattern == "" && len(node.children) == 0
+}
+
+// Match calls iter for every stored pattern matching the topic.
+func (trie *patternTrie) Match(topic string, iter func(pattern string)) {
+	trie.match(trie.root, strings.Split(topic, channels.Separator), iter)
+}
+
+func (trie *patternTrie) match(node *trieNode, tokens []string, iter func(string)) {
+	if len(tokens) == 0 {
+		if node.pattern != "" {
+			iter(node.pattern)
+		}
+		return
+	}
+	if child, ok := node.children[channels.MultiWildcard]; ok && child.pattern != "" {
+		iter(child.pattern)
+	}
+	if child, ok := node.children[channels.SingleWildcard]; ok {
+		trie.match(child, tokens[1:], iter)
+	}
+	if tokens[0] == channels.SingleWildcard || tokens[0] == channels.MultiWildcard {
+		return
+	}
+	if child, ok := node.children[tokens[0]]; ok {
+		trie.match(child, tokens[1:], iter)
+	}
+}
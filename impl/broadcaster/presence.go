@@ -0,0 +1,86 @@
+package broadcaster
+
+import (
+	"context"
+	"log"
+	"sakura"
+	"sakura/channels"
+	"sakura/core/event"
+	"time"
+)
+
+// join marks the user as connected to this node
+// and announces it on the user's topics if the user was offline everywhere.
+func (broadcaster *Broadcaster) join(ctx context.Context, userID string, topics []string) {
+	store := broadcaster.sakura.Presence()
+	if store == nil {
+		return
+	}
+
+	online, err := store.Online(ctx, userID)
+	if err != nil {
+		log.Println("failed to check the presence of a user:", err)
+		return
+	}
+	if err := store.Touch(ctx, userID, broadcaster.config.nodeID, broadcaster.config.presenceTTL); err != nil {
+		log.Println("failed to register the presence of a user:", err)
+		return
+	}
+	if len(online) == 0 {
+		broadcaster.announce(ctx, sakura.JoinEvent, userID, topics)
+	}
+}
+
+// leave removes the user from this node
+// and announces it on the user's topics if the user isn't connected anywhere else.
+func (broadcaster *Broadcaster) leave(ctx context.Context, userID string, topics []string) {
+	store := broadcaster.sakura.Presence()
+	if store == nil {
+		return
+	}
+
+	if err := store.Remove(ctx, userID, broadcaster.config.nodeID); err != nil {
+		log.Println("failed to remove the presence of a user:", err)
+		return
+	}
+	online, err := store.Online(ctx, userID)
+	if err != nil {
+		log.Println("failed to check the presence of a user:", err)
+		return
+	}
+	if len(online) == 0 {
+		broadcaster.announce(ctx, sakura.LeaveEvent, userID, topics)
+	}
+}
+
+func (broadcaster *Broadcaster) announce(ctx context.Context, name, userID string, topics []string) {
+	for _, topic := range topics {
+		if channels.IsPattern(topic) {
+			continue
+		}
+		ev := event.New(name, []byte(userID), event.WithPublisher(userID))
+		if err := broadcaster.sakura.Broker().Push(ctx, channels.FromTopic(topic), ev); err != nil {
+			log.Println("failed to announce the presence of a user:", err)
+		}
+	}
+}
+
+// heartbeat keeps the presence of the node's users from expiring.
+func (broadcaster *Broadcaster) heartbeat(ctx context.Context) {
+	store := broadcaster.sakura.Presence()
+	ticker := time.NewTicker(broadcaster.config.presenceTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, userID := range broadcaster.users.Users() {
+				if err := store.Touch(ctx, userID, broadcaster.config.nodeID, broadcaster.config.presenceTTL); err != nil {
+					log.Println("failed to refresh the presence of a user:", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package broadcaster
+
+import (
+	"context"
+	"log"
+	"sakura"
+	"sakura/core/deadletter"
+	"sakura/core/event"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons reliable messages end up in the dead-letter store with.
+const (
+	ReasonAttemptsExhausted = "delivery attempts exhausted"
+	ReasonConnectionClosed  = "connection closed and the user didn't reconnect in time"
+)
+
+// maxRedeliveryDelay bounds the exponential backoff of redeliveries.
+const maxRedeliveryDelay = time.Hour
+
+type pendingDelivery struct {
+	envelope Envelope
+	due      time.Time
+}
+
+// parkingLot keeps the unacked messages of closed connections by user,
+// until the user connects again or they expire.
+type parkingLot struct {
+	users map[string]map[string]parkedDelivery
+	mu    sync.Mutex
+}
+
+type parkedDelivery struct {
+	envelope Envelope
+	expires  time.Time
+}
+
+func newParkingLot() *parkingLot {
+	return &parkingLot{
+		users: map[string]map[string]parkedDelivery{},
+		mu:    sync.Mutex{},
+	}
+}
+
+func (lot *parkingLot) park(userID string, envelopes []Envelope, expires time.Time) {
+	if len(envelopes) == 0 {
+		return
+	}
+
+	lot.mu.Lock()
+	defer lot.mu.Unlock()
+
+	parked, ok := lot.users[userID]
+	if !ok {
+		parked = map[string]parkedDelivery{}
+		lot.users[userID] = parked
+	}
+	for _, envelope := range envelopes {
+		parked[envelope.MessageID] = parkedDelivery{envelope: envelope, expires: expires}
+	}
+}
+
+// claim takes all messages parked for the user.
+func (lot *parkingLot) claim(userID string) []Envelope {
+	lot.mu.Lock()
+	parked := lot.users[userID]
+	delete(lot.users, userID)
+	lot.mu.Unlock()
+
+	envelopes := make([]Envelope, 0, len(parked))
+	for _, delivery := range parked {
+		envelopes = append(envelopes, delivery.envelope)
+	}
+	sortByID(envelopes)
+	return envelopes
+}
+
+// expire takes the messages that have waited for their users too long.
+func (lot *parkingLot) expire(now time.Time) map[string][]Envelope {
+	lot.mu.Lock()
+	defer lot.mu.Unlock()
+
+	expired := map[string][]Envelope{}
+	for userID, parked := range lot.users {
+		for id, delivery := range parked {
+			if now.Before(delivery.expires) {
+				continue
+			}
+			expired[userID] = append(expired[userID], delivery.envelope)
+			delete(parked, id)
+		}
+		if len(parked) == 0 {
+			delete(lot.users, userID)
+		}
+		sortByID(expired[userID])
+	}
+	return expired
+}
+
+// Ack confirms that the connection has received the messages, unknown ids are ignored.
+func (broadcaster *Broadcaster) Ack(connectionID string, messageIDs ...string) {
+	conn, ok := broadcaster.users.Connection(connectionID)
+	if !ok {
+		return
+	}
+	conn.ack(messageIDs...)
+}
+
+// DeadLetters returns the dead-letter store set with WithDeadLetters or nil.
+func (broadcaster *Broadcaster) DeadLetters() deadletter.Store {
+	return broadcaster.config.deadLetters
+}
+
+// track marks the envelope as requiring an ack if its topic is reliable and the connection acks,
+// and starts waiting for the ack. Messages without an id get one, so they can be acked.
+func (broadcaster *Broadcaster) track(conn *connection, envelope Envelope) Envelope {
+	reliable := broadcaster.config.reliable
+	if reliable == nil || conn.withoutAcks || envelope.Event != sakura.PublishEvent || !reliable(envelope.Topic) {
+		return envelope
+	}
+
+	now := time.Now()
+	if envelope.MessageID == "" {
+		envelope.MessageID = event.NewID(now)
+	}
+	envelope.Ack = true
+	envelope.Attempt = 1
+	conn.wait(envelope, now.Add(broadcaster.config.redeliveryBackoff))
+	return envelope
+}
+
+// resume hands the messages parked for the user to the new connection as their next attempt.
+func (broadcaster *Broadcaster) resume(conn *connection) {
+	if conn.withoutAcks {
+		return
+	}
+	parked := broadcaster.parked.claim(conn.user.ID())
+	if len(parked) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for i := range parked {
+		parked[i].Attempt++
+		conn.wait(parked[i], now.Add(redeliveryDelay(broadcaster.config.redeliveryBackoff, parked[i].Attempt)))
+	}
+	conn.prepend(parked)
+}
+
+// redeliver resends unacked messages whose backoff has passed until the context is done.
+func (broadcaster *Broadcaster) redeliver(ctx context.Context) {
+	ticker := time.NewTicker(broadcaster.config.redeliveryBackoff / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			var conns []*connection
+			broadcaster.users.Iter(func(conn *connection) {
+				conns = append(conns, conn)
+			})
+
+			for _, conn := range conns {
+				due, exhausted := conn.due(now, broadcaster.config.maxAttempts, broadcaster.config.redeliveryBackoff)
+				for _, envelope := range due {
+					broadcaster.deliver(conn, envelope)
+				}
+				broadcaster.bury(ctx, conn.user.ID(), exhausted, ReasonAttemptsExhausted)
+			}
+			for userID, expired := range broadcaster.parked.expire(now) {
+				broadcaster.bury(ctx, userID, expired, ReasonConnectionClosed)
+			}
+		}
+	}
+}
+
+// abandon parks the unacked messages of the user's last closed connection until the user reconnects,
+// they go to the dead-letter store if that doesn't happen within the pending ttl.
+func (broadcaster *Broadcaster) abandon(conn *connection) {
+	broadcaster.parked.park(conn.user.ID(), conn.unacked(), time.Now().Add(broadcaster.config.pendingTTL))
+}
+
+func (broadcaster *Broadcaster) bury(ctx context.Context, userID string, envelopes []Envelope, reason string) {
+	for _, envelope := range envelopes {
+		if broadcaster.config.deadLetters == nil {
+			log.Printf("message %s of topic %s was not delivered to %s: %s", envelope.MessageID, envelope.Topic, userID, reason)
+			continue
+		}
+
+		err := broadcaster.config.deadLetters.Put(ctx, deadletter.Letter{
+			User:     userID,
+			Topic:    envelope.Topic,
+			Event:    envelope.event(),
+			Attempts: envelope.Attempt,
+			Reason:   reason,
+			Time:     time.Now(),
+		})
+		if err != nil {
+			log.Println("failed to store a dead letter:", err)
+		}
+	}
+}
+
+func (conn *connection) wait(envelope Envelope, due time.Time) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.ctx.Err() != nil {
+		return
+	}
+	conn.pending[envelope.MessageID] = &pendingDelivery{envelope: envelope, due: due}
+}
+
+func (conn *connection) ack(ids ...string) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	for _, id := range ids {
+		delete(conn.pending, id)
+	}
+}
+
+// due returns the envelopes to redeliver and stops waiting for those out of attempts.
+func (conn *connection) due(now time.Time, maxAttempts int, backoff time.Duration) (due, exhausted []Envelope) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	for id, delivery := range conn.pending {
+		if now.Before(delivery.due) {
+			continue
+		}
+		if delivery.envelope.Attempt >= maxAttempts {
+			delete(conn.pending, id)
+			exhausted = append(exhausted, delivery.envelope)
+			continue
+		}
+		delivery.envelope.Attempt++
+		delivery.due = now.Add(redeliveryDelay(backoff, delivery.envelope.Attempt))
+		due = append(due, delivery.envelope)
+	}
+
+	sortByID(due)
+	sortByID(exhausted)
+	return due, exhausted
+}
+
+// unacked stops waiting for all acks and returns the envelopes that weren't acked.
+func (conn *connection) unacked() []Envelope {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	envelopes := make([]Envelope, 0, len(conn.pending))
+	for _, delivery := range conn.pending {
+		envelopes = append(envelopes, delivery.envelope)
+	}
+	conn.pending = map[string]*pendingDelivery{}
+
+	sortByID(envelopes)
+	return envelopes
+}
+
+// redeliveryDelay returns how long the attempt waits for its ack:
+// the backoff doubles with every attempt after the first, up to maxRedeliveryDelay.
+func redeliveryDelay(backoff time.Duration, attempt int) time.Duration {
+	delay := backoff
+	for i := 1; i < attempt && delay < maxRedeliveryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRedeliveryDelay {
+		return maxRedeliveryDelay
+	}
+	return delay
+}
+
+func sortByID(envelopes []Envelope) {
+	sort.Slice(envelopes, func(i, j int) bool { return envelopes[i].MessageID < envelopes[j].MessageID })
+}
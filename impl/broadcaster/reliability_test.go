@@ -0,0 +1,27 @@
+package broadcaster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedeliveryDelay(t *testing.T) {
+	tests := []struct {
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{time.Second, 1, time.Second},
+		{time.Second, 2, 2 * time.Second},
+		{time.Second, 4, 8 * time.Second},
+		{time.Second, 13, maxRedeliveryDelay},
+		{time.Second, 100, maxRedeliveryDelay},
+		{time.Millisecond, 1 << 20, maxRedeliveryDelay},
+		{2 * maxRedeliveryDelay, 1, maxRedeliveryDelay},
+	}
+	for _, test := range tests {
+		if got := redeliveryDelay(test.backoff, test.attempt); got != test.want {
+			t.Fatalf("backoff %v, attempt %d: got %v, want %v", test.backoff, test.attempt, got, test.want)
+		}
+	}
+}
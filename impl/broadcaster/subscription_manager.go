@@ -1,54 +1,79 @@
 package broadcaster
 
-import "sync"
+import (
+	"sakura/channels"
+	"sync"
+)
 
+// SubscriptionManager tracks the subscriptions of the node's users.
+// Pattern subscriptions are stored like plain ones and are also indexed in a trie for matching.
 type SubscriptionManager struct {
-	topics map[string]map[string]struct{}
-	users  map[string]map[string]struct{}
-	mu     sync.RWMutex
+	topics   map[string]map[string]struct{}
+	users    map[string]map[string]struct{}
+	patterns *patternTrie
+	mu       sync.RWMutex
 }
 
 func newSubscriptionManager() *SubscriptionManager {
 	return &SubscriptionManager{
-		topics: map[string]map[string]struct{}{},
-		users:  map[string]map[string]struct{}{},
-		mu:     sync.RWMutex{},
+		topics:   map[string]map[string]struct{}{},
+		users:    map[string]map[string]struct{}{},
+		patterns: newPatternTrie(),
+		mu:       sync.RWMutex{},
 	}
 }
 
-func (manager *SubscriptionManager) Add(topic, user string) {
+// Add reports whether the subscription is new.
+func (manager *SubscriptionManager) Add(topic, user string) bool {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
+	if _, ok := manager.topics[topic][user]; ok {
+		return false
+	}
+
 	manager.initUser(user)
 	manager.initTopic(topic)
 
 	manager.topics[topic][user] = struct{}{}
 	manager.users[user][topic] = struct{}{}
+	return true
 }
 
-func (manager *SubscriptionManager) Remove(topic, user string) {
+// Remove reports whether the subscription existed.
+func (manager *SubscriptionManager) Remove(topic, user string) bool {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	if topicInfo, ok := manager.topics[topic]; ok {
-		delete(topicInfo, topic)
+	if _, ok := manager.topics[topic][user]; !ok {
+		return false
 	}
 
-	if userInfo, ok := manager.users[user]; ok {
-		delete(userInfo, user)
+	manager.removeFromTopic(topic, user)
+
+	delete(manager.users[user], topic)
+	if len(manager.users[user]) == 0 {
+		delete(manager.users, user)
 	}
+	return true
 }
 
+// Iter calls iter once for every user subscribed to the topic directly or through a pattern.
 func (manager *SubscriptionManager) Iter(topic string, iter func(string)) {
 	var ids []string
-
-	manager.mu.RLock()
-	if data, ok := manager.topics[topic]; ok {
-		for id := range data {
-			ids = append(ids, id)
+	unique := map[string]struct{}{}
+	collect := func(topic string) {
+		for id := range manager.topics[topic] {
+			if _, ok := unique[id]; !ok {
+				unique[id] = struct{}{}
+				ids = append(ids, id)
+			}
 		}
 	}
+
+	manager.mu.RLock()
+	collect(topic)
+	manager.patterns.Match(topic, collect)
 	manager.mu.RUnlock()
 
 	for _, id := range ids {
@@ -56,26 +81,42 @@ func (manager *SubscriptionManager) Iter(topic string, iter func(string)) {
 	}
 }
 
-func (manager *SubscriptionManager) RemoveByUser(id string) {
+// RemoveByUser returns the topics the user was subscribed to.
+func (manager *SubscriptionManager) RemoveByUser(id string) []string {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
+
+	var topics []string
 	if user, ok := manager.users[id]; ok {
 		delete(manager.users, id)
 		for topicID := range user {
-			delete(manager.topics[topicID], id)
+			topics = append(topics, topicID)
+			manager.removeFromTopic(topicID, id)
 		}
 	}
+	return topics
 }
 
-func (manager *SubscriptionManager) RemoveByTopic(id string) {
+// RemoveByTopic returns the users that were subscribed to the topic.
+func (manager *SubscriptionManager) RemoveByTopic(id string) []string {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
+
+	var users []string
 	if topic, ok := manager.topics[id]; ok {
 		delete(manager.topics, id)
+		if channels.IsPattern(id) {
+			manager.patterns.Remove(id)
+		}
 		for userID := range topic {
+			users = append(users, userID)
 			delete(manager.users[userID], id)
+			if len(manager.users[userID]) == 0 {
+				delete(manager.users, userID)
+			}
 		}
 	}
+	return users
 }
 
 func (manager *SubscriptionManager) initUser(id string) {
@@ -87,5 +128,18 @@ func (manager *SubscriptionManager) initUser(id string) {
 func (manager *SubscriptionManager) initTopic(id string) {
 	if _, ok := manager.topics[id]; !ok {
 		manager.topics[id] = map[string]struct{}{}
+		if channels.IsPattern(id) {
+			manager.patterns.Insert(id)
+		}
+	}
+}
+
+func (manager *SubscriptionManager) removeFromTopic(topic, user string) {
+	delete(manager.topics[topic], user)
+	if len(manager.topics[topic]) == 0 {
+		delete(manager.topics, topic)
+		if channels.IsPattern(topic) {
+			manager.patterns.Remove(topic)
+		}
 	}
 }
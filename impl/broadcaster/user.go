@@ -1,8 +1,61 @@
 package broadcaster
 
-import "context"
+import (
+	"context"
+	"sakura/core/event"
+	"time"
+)
 
 type User interface {
 	ID() string
 	Send(ctx context.Context, payload []byte) error
 }
+
+// Envelope wraps a delivered payload with the information about where it came from.
+// Direct messages have no topic. Envelopes of reliable topics have Ack set, they must be acked
+// by their MessageID and are redelivered with a growing Attempt until they are.
+type Envelope struct {
+	Topic       string            `json:"topic"`
+	Event       string            `json:"event"`
+	MessageID   string            `json:"message_id,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Publisher   string            `json:"publisher,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Data        []byte            `json:"data"`
+	Ack         bool              `json:"ack,omitempty"`
+	Attempt     int               `json:"attempt,omitempty"`
+}
+
+func newEnvelope(topic string, ev event.Event) Envelope {
+	return Envelope{
+		Topic:       topic,
+		Event:       ev.Name,
+		MessageID:   ev.ID,
+		Timestamp:   ev.Timestamp,
+		Publisher:   ev.Publisher,
+		ContentType: ev.ContentType,
+		Headers:     ev.Headers,
+		Data:        ev.Data,
+	}
+}
+
+func (envelope Envelope) event() event.Event {
+	return event.Event{
+		Name: envelope.Event,
+		Data: envelope.Data,
+		Metadata: event.Metadata{
+			ID:          envelope.MessageID,
+			Timestamp:   envelope.Timestamp,
+			Publisher:   envelope.Publisher,
+			ContentType: envelope.ContentType,
+			Headers:     envelope.Headers,
+		},
+	}
+}
+
+// EnvelopeUser receives whole envelopes instead of bare payloads, Send is never called for it.
+type EnvelopeUser interface {
+	User
+	SendEnvelope(ctx context.Context, envelope Envelope) error
+}
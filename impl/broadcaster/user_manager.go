@@ -3,35 +3,116 @@ package broadcaster
 import "sync"
 
 type UserManager struct {
-	data map[string]User
-	mu   sync.RWMutex
+	// connections of every user, oldest first
+	data        map[string][]*connection
+	connections map[string]*connection
+	mu          sync.RWMutex
 }
 
 func newUserManager() *UserManager {
 	return &UserManager{
-		data: map[string]User{},
-		mu:   sync.RWMutex{},
+		data:        map[string][]*connection{},
+		connections: map[string]*connection{},
+		mu:          sync.RWMutex{},
 	}
 }
 
-func (manager *UserManager) Add(user User) {
+// Add reports whether it's the first connection of the user.
+func (manager *UserManager) Add(conn *connection) bool {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	manager.data[user.ID()] = user
+	userID := conn.user.ID()
+	first := len(manager.data[userID]) == 0
+	manager.data[userID] = append(manager.data[userID], conn)
+	manager.connections[conn.id] = conn
+	return first
 }
 
-func (manager *UserManager) Delete(id string) {
+// Delete removes the connection and reports whether it was the last connection of its user.
+func (manager *UserManager) Delete(id string) (*connection, bool, bool) {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	delete(manager.data, id)
+	conn, ok := manager.connections[id]
+	if !ok {
+		return nil, false, false
+	}
+	delete(manager.connections, id)
+
+	userID := conn.user.ID()
+	conns := manager.data[userID]
+	for i, c := range conns {
+		if c == conn {
+			conns = append(conns[:i:i], conns[i+1:]...)
+			break
+		}
+	}
+
+	if len(conns) == 0 {
+		delete(manager.data, userID)
+		return conn, true, true
+	}
+	manager.data[userID] = conns
+	return conn, false, true
 }
 
-func (manager *UserManager) Get(id string) (User, bool) {
+// Get returns all connections of the user.
+func (manager *UserManager) Get(id string) []*connection {
 	manager.mu.RLock()
 	defer manager.mu.RUnlock()
 
-	user, ok := manager.data[id]
-	return user, ok
+	return append([]*connection(nil), manager.data[id]...)
+}
+
+func (manager *UserManager) Connection(id string) (*connection, bool) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	conn, ok := manager.connections[id]
+	return conn, ok
+}
+
+// Connections returns the ids of the user's connections, oldest first.
+func (manager *UserManager) Connections(id string) []string {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	ids := make([]string, 0, len(manager.data[id]))
+	for _, conn := range manager.data[id] {
+		ids = append(ids, conn.id)
+	}
+	return ids
+}
+
+func (manager *UserManager) Online(id string) bool {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	return len(manager.data[id]) > 0
+}
+
+// Users returns the ids of all connected users.
+func (manager *UserManager) Users() []string {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	ids := make([]string, 0, len(manager.data))
+	for id := range manager.data {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (manager *UserManager) Iter(iter func(*connection)) {
+	manager.mu.RLock()
+	conns := make([]*connection, 0, len(manager.connections))
+	for _, conn := range manager.connections {
+		conns = append(conns, conn)
+	}
+	manager.mu.RUnlock()
+
+	for _, conn := range conns {
+		iter(conn)
+	}
 }
@@ -0,0 +1,251 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"io"
+	"log"
+	"sakura/channels"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"strings"
+	"sync"
+)
+
+var (
+	_ broker.Broker[any]        = (*Broker[any])(nil)
+	_ broker.PatternPubSub[any] = (*PubSub[any])(nil)
+)
+
+// channelHeader carries the Sakura channel of a Kafka message.
+const channelHeader = "sakura-channel"
+
+// Broker multiplexes all channels into a single Kafka topic. Messages are keyed by the Sakura topic,
+// so every topic lands in a single partition and keeps its order. Kafka hands every message to one
+// consumer per group, so every node reads the whole topic through a group of its own
+// and keeps the messages of the channels it's subscribed to.
+type Broker[T any] struct {
+	brokers   []string
+	writer    writer
+	newReader func(kafka.ReaderConfig) reader
+	codec     codec.Binary[T]
+	group     string
+	config    config
+}
+
+// writer and reader are the parts of kafka.Writer and kafka.Reader the broker uses.
+type writer interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+type reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+func newReader(config kafka.ReaderConfig) reader {
+	return kafka.NewReader(config)
+}
+
+// New creates a broker for the node consuming through the group. The group must be unique to the node
+// and stable across its restarts, so the node resumes from the group's committed offsets: container
+// hostnames usually change on restart, use e.g. a StatefulSet pod name. A node started under a new group
+// begins at the start offset (see WithStartOffset). Groups of nodes that are gone keep only their offsets,
+// the cluster expires them after offsets.retention.minutes (a week by default) or they may be deleted
+// with kafka-consumer-groups.sh --delete.
+func New[T any](brokers []string, codec codec.Binary[T], group string, opts ...Option) *Broker[T] {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Broker[T]{
+		brokers: brokers,
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        cfg.topic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: cfg.batchTimeout,
+			RequiredAcks: kafka.RequireAll,
+		},
+		newReader: newReader,
+		codec:     codec,
+		group:     group,
+		config:    cfg,
+	}
+}
+
+func (b *Broker[T]) Push(ctx context.Context, channel string, message T) error {
+	payload, err := b.codec.Encoder().Convert(message)
+	if err != nil {
+		return err
+	}
+
+	return b.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(partitionKey(channel)),
+		Value:   payload,
+		Headers: []kafka.Header{{Key: channelHeader, Value: []byte(channel)}},
+	})
+}
+
+func (b *Broker[T]) PubSub() broker.PubSub[T] {
+	return &PubSub[T]{
+		broker:   b,
+		channels: map[string]struct{}{},
+		patterns: map[string]struct{}{},
+		mu:       sync.RWMutex{},
+	}
+}
+
+// Close flushes pending messages and closes the writer.
+func (b *Broker[T]) Close() error {
+	return b.writer.Close()
+}
+
+// partitionKey keys topic channels by the topic id,
+// other channels (e.g. users' ones) are keyed by the whole channel.
+func partitionKey(channel string) string {
+	if strings.HasPrefix(channel, channels.FromTopic("")) {
+		return channels.ParseTopic(channel)
+	}
+	return channel
+}
+
+// PubSub matches the channel header of every message of the topic against its subscriptions:
+// Kafka consumers can't subscribe to anything finer than a topic, so subscribing is local.
+type PubSub[T any] struct {
+	broker *Broker[T]
+
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	mu       sync.RWMutex
+}
+
+func (p *PubSub[T]) Subscribe(ctx context.Context, channels ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, channel := range channels {
+		p.channels[channel] = struct{}{}
+	}
+	return nil
+}
+
+func (p *PubSub[T]) Unsubscribe(ctx context.Context, channels ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, channel := range channels {
+		delete(p.channels, channel)
+	}
+	return nil
+}
+
+func (p *PubSub[T]) PSubscribe(ctx context.Context, patterns ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pattern := range patterns {
+		p.patterns[pattern] = struct{}{}
+	}
+	return nil
+}
+
+func (p *PubSub[T]) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pattern := range patterns {
+		delete(p.patterns, pattern)
+	}
+	return nil
+}
+
+func (p *PubSub[T]) Clear(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.channels = map[string]struct{}{}
+	p.patterns = map[string]struct{}{}
+	return nil
+}
+
+// Channel starts consuming the topic, offsets are committed once messages are handed to the returned channel.
+func (p *PubSub[T]) Channel(ctx context.Context) (<-chan broker.Message[T], error) {
+	reader := p.broker.newReader(kafka.ReaderConfig{
+		Brokers:        p.broker.brokers,
+		Topic:          p.broker.config.topic,
+		GroupID:        p.broker.group,
+		StartOffset:    p.broker.config.startOffset,
+		CommitInterval: p.broker.config.commitInterval,
+	})
+	outputs := make(chan broker.Message[T], p.broker.config.bufferSize)
+
+	go func() {
+		defer close(outputs)
+		defer func() {
+			if err := reader.Close(); err != nil {
+				log.Println("failed to close the kafka reader:", err)
+			}
+		}()
+
+		for {
+			rawMessage, err := reader.FetchMessage(ctx)
+			if err != nil {
+				if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+					log.Println("failed to fetch a message:", err)
+				}
+				return
+			}
+
+			if channel, ok := p.accept(rawMessage); ok {
+				message, err := p.broker.codec.Decoder().Convert(rawMessage.Value)
+				if err != nil {
+					log.Println("failed to decode the message:", err)
+				} else {
+					select {
+					case outputs <- broker.Message[T]{Channel: channel, Data: message}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			if err := reader.CommitMessages(ctx, rawMessage); err != nil && ctx.Err() == nil {
+				log.Println("failed to commit a message:", err)
+			}
+		}
+	}()
+
+	return outputs, nil
+}
+
+// accept returns the channel of the message if the pubsub is subscribed to it.
+func (p *PubSub[T]) accept(message kafka.Message) (string, bool) {
+	var channel string
+	for _, header := range message.Headers {
+		if header.Key == channelHeader {
+			channel = string(header.Value)
+			break
+		}
+	}
+	if channel == "" {
+		return "", false
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if _, ok := p.channels[channel]; ok {
+		return channel, true
+	}
+	for pattern := range p.patterns {
+		if channels.Match(pattern, channel) {
+			return channel, true
+		}
+	}
+	return "", false
+}
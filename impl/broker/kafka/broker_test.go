@@ -0,0 +1,293 @@
+package kafka
+
+import (
+	"context"
+	"github.com/segmentio/kafka-go"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"sync"
+	"testing"
+	"time"
+)
+
+var stringCodec = codec.New(
+	func(value string) ([]byte, error) { return []byte(value), nil },
+	func(payload []byte) (string, error) { return string(payload), nil },
+)
+
+// cluster is an in-process fake of a single-partition Kafka topic with consumer groups.
+type cluster struct {
+	messages []kafka.Message
+	offsets  map[string]int64
+	appended chan struct{}
+	mu       sync.Mutex
+}
+
+func newCluster() *cluster {
+	return &cluster{
+		offsets:  map[string]int64{},
+		appended: make(chan struct{}),
+		mu:       sync.Mutex{},
+	}
+}
+
+func (c *cluster) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, message := range messages {
+		message.Offset = int64(len(c.messages))
+		c.messages = append(c.messages, message)
+	}
+	close(c.appended)
+	c.appended = make(chan struct{})
+	return nil
+}
+
+func (c *cluster) Close() error {
+	return nil
+}
+
+func (c *cluster) reader(config kafka.ReaderConfig) reader {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	offset, ok := c.offsets[config.GroupID]
+	if !ok {
+		offset = 0
+		if config.StartOffset == LastOffset {
+			offset = int64(len(c.messages))
+		}
+	}
+	return &groupReader{cluster: c, group: config.GroupID, offset: offset}
+}
+
+type groupReader struct {
+	cluster *cluster
+	group   string
+	offset  int64
+}
+
+func (r *groupReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		r.cluster.mu.Lock()
+		if r.offset < int64(len(r.cluster.messages)) {
+			message := r.cluster.messages[r.offset]
+			r.offset++
+			r.cluster.mu.Unlock()
+			return message, nil
+		}
+		appended := r.cluster.appended
+		r.cluster.mu.Unlock()
+
+		select {
+		case <-appended:
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		}
+	}
+}
+
+func (r *groupReader) CommitMessages(ctx context.Context, messages ...kafka.Message) error {
+	r.cluster.mu.Lock()
+	defer r.cluster.mu.Unlock()
+
+	for _, message := range messages {
+		if message.Offset+1 > r.cluster.offsets[r.group] {
+			r.cluster.offsets[r.group] = message.Offset + 1
+		}
+	}
+	return nil
+}
+
+func (r *groupReader) Close() error {
+	return nil
+}
+
+func newBroker(c *cluster, group string, opts ...Option) *Broker[string] {
+	b := New[string](nil, stringCodec, group, opts...)
+	b.writer = c
+	b.newReader = c.reader
+	return b
+}
+
+func push(t *testing.T, b *Broker[string], channel string, messages ...string) {
+	t.Helper()
+
+	for _, message := range messages {
+		if err := b.Push(context.Background(), channel, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func receive(t *testing.T, messages <-chan broker.Message[string], n int) []broker.Message[string] {
+	t.Helper()
+
+	var received []broker.Message[string]
+	timeout := time.After(time.Second)
+	for len(received) < n {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				t.Fatalf("channel closed after %d messages", len(received))
+			}
+			received = append(received, message)
+		case <-timeout:
+			t.Fatalf("received %d of %d messages", len(received), n)
+		}
+	}
+	return received
+}
+
+func TestEveryGroupReceivesEverything(t *testing.T) {
+	c := newCluster()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var outputs []<-chan broker.Message[string]
+	for _, group := range []string{"node-1", "node-2"} {
+		pubsub := newBroker(c, group, WithStartOffset(FirstOffset)).PubSub()
+		if err := pubsub.Subscribe(ctx, "topic/chat"); err != nil {
+			t.Fatal(err)
+		}
+		messages, err := pubsub.Channel(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, messages)
+	}
+
+	push(t, newBroker(c, "publisher"), "topic/chat", "1", "2", "3")
+
+	for _, messages := range outputs {
+		received := receive(t, messages, 3)
+		for i, want := range []string{"1", "2", "3"} {
+			if received[i].Channel != "topic/chat" || received[i].Data != want {
+				t.Fatalf("message %d: got %+v, want %s", i, received[i], want)
+			}
+		}
+	}
+}
+
+func TestSubscriptionsFilterLocally(t *testing.T) {
+	c := newCluster()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pubsub := newBroker(c, "node", WithStartOffset(FirstOffset)).PubSub().(broker.PatternPubSub[string])
+	if err := pubsub.Subscribe(ctx, "user/alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.PSubscribe(ctx, "topic/chat.*"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := newBroker(c, "publisher")
+	push(t, publisher, "user/bob", "skipped")
+	push(t, publisher, "topic/news", "skipped")
+	push(t, publisher, "topic/chat.room", "pattern")
+	push(t, publisher, "user/alice", "direct")
+
+	received := receive(t, messages, 2)
+	got := map[string]string{}
+	for _, message := range received {
+		got[message.Channel] = message.Data
+	}
+	if got["topic/chat.room"] != "pattern" || got["user/alice"] != "direct" {
+		t.Fatalf("got %v", received)
+	}
+}
+
+func TestGroupResumesFromCommittedOffsets(t *testing.T) {
+	c := newCluster()
+	publisher := newBroker(c, "publisher")
+	node := newBroker(c, "node", WithStartOffset(FirstOffset))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pubsub := node.PubSub()
+	if err := pubsub.Subscribe(ctx, "topic/chat"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, publisher, "topic/chat", "before")
+	receive(t, messages, 1)
+	cancel()
+	for range messages {
+	}
+
+	push(t, publisher, "topic/chat", "while down")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	pubsub = node.PubSub()
+	if err := pubsub.Subscribe(ctx, "topic/chat"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err = pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received := receive(t, messages, 1); received[0].Data != "while down" {
+		t.Fatalf("got %+v", received[0])
+	}
+}
+
+func TestNewGroupStartsAtStartOffset(t *testing.T) {
+	c := newCluster()
+	push(t, newBroker(c, "publisher"), "topic/chat", "history")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pubsub := newBroker(c, "node").PubSub()
+	if err := pubsub.Subscribe(ctx, "topic/chat"); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push(t, newBroker(c, "publisher"), "topic/chat", "live")
+	if received := receive(t, messages, 1); received[0].Data != "live" {
+		t.Fatalf("got %+v", received[0])
+	}
+}
+
+func TestPushKeysByTopic(t *testing.T) {
+	c := newCluster()
+	publisher := newBroker(c, "publisher")
+	push(t, publisher, "topic/chat", "1")
+	push(t, publisher, "user/alice", "2")
+
+	tests := []struct {
+		key     string
+		channel string
+	}{
+		{key: "chat", channel: "topic/chat"},
+		{key: "user/alice", channel: "user/alice"},
+	}
+	for i, test := range tests {
+		message := c.messages[i]
+		if string(message.Key) != test.key {
+			t.Errorf("message %d: got key %q, want %q", i, message.Key, test.key)
+		}
+		if len(message.Headers) != 1 || string(message.Headers[0].Value) != test.channel {
+			t.Errorf("message %d: got headers %v, want channel %q", i, message.Headers, test.channel)
+		}
+	}
+}
+
+func TestNegativeBufferSizeKeepsDefault(t *testing.T) {
+	b := New[string](nil, stringCodec, "node-1", WithBufferSize(-1))
+	if b.config.bufferSize != DefaultBufferSize {
+		t.Fatalf("got buffer size %d, want %d", b.config.bufferSize, DefaultBufferSize)
+	}
+}
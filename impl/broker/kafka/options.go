@@ -0,0 +1,79 @@
+package kafka
+
+import (
+	"github.com/segmentio/kafka-go"
+	"time"
+)
+
+const (
+	DefaultTopic          = "sakura"
+	DefaultBufferSize     = 512
+	DefaultBatchTimeout   = 10 * time.Millisecond
+	DefaultCommitInterval = time.Second
+)
+
+// Offsets a consumer group without committed offsets starts from.
+const (
+	FirstOffset = kafka.FirstOffset
+	LastOffset  = kafka.LastOffset
+)
+
+type config struct {
+	topic          string
+	startOffset    int64
+	bufferSize     int
+	batchTimeout   time.Duration
+	commitInterval time.Duration
+}
+
+func defaultConfig() config {
+	return config{
+		topic:          DefaultTopic,
+		startOffset:    LastOffset,
+		bufferSize:     DefaultBufferSize,
+		batchTimeout:   DefaultBatchTimeout,
+		commitInterval: DefaultCommitInterval,
+	}
+}
+
+type Option func(*config)
+
+// WithTopic sets the Kafka topic all Sakura channels are multiplexed into, it must already exist.
+func WithTopic(topic string) Option {
+	return func(c *config) {
+		c.topic = topic
+	}
+}
+
+// WithStartOffset sets where a group without committed offsets starts reading: FirstOffset or LastOffset.
+// It applies to a new group and to one whose offsets the cluster expired while the node was down.
+func WithStartOffset(offset int64) Option {
+	return func(c *config) {
+		c.startOffset = offset
+	}
+}
+
+// WithBufferSize sets how many fetched messages may wait in a channel returned by PubSub.Channel,
+// their offsets are committed as soon as they're buffered. Negative sizes keep the default.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		if size >= 0 {
+			c.bufferSize = size
+		}
+	}
+}
+
+// WithBatchTimeout sets how long Push may wait for other messages to be batched with.
+func WithBatchTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.batchTimeout = timeout
+	}
+}
+
+// WithCommitInterval sets how often consumed offsets are committed,
+// a node restarting after a crash may receive up to that much of messages again.
+func WithCommitInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.commitInterval = interval
+	}
+}
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"sakura/channels"
+	"sakura/core/broker"
+	"sync"
+)
+
+var (
+	_ broker.Broker[any]        = (*Broker[any])(nil)
+	_ broker.PatternPubSub[any] = (*PubSub[any])(nil)
+)
+
+type Broker[T any] struct {
+	channels map[string]map[*PubSub[T]]struct{}
+	patterns map[string]map[*PubSub[T]]struct{}
+	config   config
+	mu       sync.RWMutex
+}
+
+func New[T any](opts ...Option) *Broker[T] {
+	cfg := config{
+		bufferSize: DefaultBufferSize,
+		policy:     Block,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Broker[T]{
+		channels: map[string]map[*PubSub[T]]struct{}{},
+		patterns: map[string]map[*PubSub[T]]struct{}{},
+		config:   cfg,
+		mu:       sync.RWMutex{},
+	}
+}
+
+func (b *Broker[T]) Push(ctx context.Context, channel string, message T) error {
+	b.mu.RLock()
+	// a pubsub subscribed to the channel through several patterns receives the message once
+	unique := map[*PubSub[T]]struct{}{}
+	subscribers := make([]*PubSub[T], 0, len(b.channels[channel]))
+	collect := func(pubsubs map[*PubSub[T]]struct{}) {
+		for pubsub := range pubsubs {
+			if _, ok := unique[pubsub]; ok {
+				continue
+			}
+			unique[pubsub] = struct{}{}
+			subscribers = append(subscribers, pubsub)
+		}
+	}
+	collect(b.channels[channel])
+	for pattern, pubsubs := range b.patterns {
+		if channels.Match(pattern, channel) {
+			collect(pubsubs)
+		}
+	}
+	b.mu.RUnlock()
+
+	msg := broker.Message[T]{
+		Channel: channel,
+		Data:    message,
+	}
+	for _, pubsub := range subscribers {
+		if !pubsub.deliver(ctx, msg) {
+			pubsub.close()
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (b *Broker[T]) PubSub() broker.PubSub[T] {
+	return &PubSub[T]{
+		broker:   b,
+		channels: map[string]struct{}{},
+		patterns: map[string]struct{}{},
+		output:   make(chan broker.Message[T], b.config.bufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+func (b *Broker[T]) subscribe(pubsub *PubSub[T], channels ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, channel := range channels {
+		add(b.channels, pubsub, channel)
+		pubsub.channels[channel] = struct{}{}
+	}
+}
+
+func (b *Broker[T]) unsubscribe(pubsub *PubSub[T], channels ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, channel := range channels {
+		delete(pubsub.channels, channel)
+		remove(b.channels, pubsub, channel)
+	}
+}
+
+func (b *Broker[T]) psubscribe(pubsub *PubSub[T], patterns ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, pattern := range patterns {
+		add(b.patterns, pubsub, pattern)
+		pubsub.patterns[pattern] = struct{}{}
+	}
+}
+
+func (b *Broker[T]) punsubscribe(pubsub *PubSub[T], patterns ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, pattern := range patterns {
+		delete(pubsub.patterns, pattern)
+		remove(b.patterns, pubsub, pattern)
+	}
+}
+
+func (b *Broker[T]) unsubscribeAll(pubsub *PubSub[T]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for channel := range pubsub.channels {
+		remove(b.channels, pubsub, channel)
+	}
+	for pattern := range pubsub.patterns {
+		remove(b.patterns, pubsub, pattern)
+	}
+	pubsub.channels = map[string]struct{}{}
+	pubsub.patterns = map[string]struct{}{}
+}
+
+func add[T any](index map[string]map[*PubSub[T]]struct{}, pubsub *PubSub[T], key string) {
+	if _, ok := index[key]; !ok {
+		index[key] = map[*PubSub[T]]struct{}{}
+	}
+	index[key][pubsub] = struct{}{}
+}
+
+func remove[T any](index map[string]map[*PubSub[T]]struct{}, pubsub *PubSub[T], key string) {
+	if subscribers, ok := index[key]; ok {
+		delete(subscribers, pubsub)
+		if len(subscribers) == 0 {
+			delete(index, key)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sakura/core/broker"
+	"testing"
+	"time"
+)
+
+func subscribe(t *testing.T, ctx context.Context, b *Broker[int], channels ...string) <-chan broker.Message[int] {
+	t.Helper()
+
+	pubsub := b.PubSub()
+	if err := pubsub.Subscribe(ctx, channels...); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func push(t *testing.T, b *Broker[int], messages ...int) {
+	t.Helper()
+
+	for _, message := range messages {
+		if err := b.Push(context.Background(), "chat", message); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// drain returns the buffered messages, the channel is closed if ok is false.
+func drain(messages <-chan broker.Message[int]) (data []int, ok bool) {
+	for {
+		select {
+		case message, ok := <-messages:
+			if !ok {
+				return data, false
+			}
+			data = append(data, message.Data)
+		default:
+			return data, true
+		}
+	}
+}
+
+func assertMessages(t *testing.T, got []int, want ...int) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBlock(t *testing.T) {
+	b := New[int](WithBufferSize(2), WithPolicy(Block))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := subscribe(t, ctx, b, "chat")
+	push(t, b, 1, 2)
+
+	pushCtx, pushCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer pushCancel()
+	if err := b.Push(pushCtx, "chat", 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	pushed := make(chan error)
+	go func() { pushed <- b.Push(context.Background(), "chat", 4) }()
+	if message := <-messages; message.Data != 1 {
+		t.Fatalf("got %d, want 1", message.Data)
+	}
+	if err := <-pushed; err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := drain(messages)
+	assertMessages(t, data, 2, 4)
+}
+
+func TestDropOldest(t *testing.T) {
+	b := New[int](WithBufferSize(2), WithPolicy(DropOldest))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := subscribe(t, ctx, b, "chat")
+	push(t, b, 1, 2, 3, 4)
+
+	data, _ := drain(messages)
+	assertMessages(t, data, 3, 4)
+}
+
+func TestDropNewest(t *testing.T) {
+	b := New[int](WithBufferSize(2), WithPolicy(DropNewest))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := subscribe(t, ctx, b, "chat")
+	push(t, b, 1, 2, 3, 4)
+
+	data, _ := drain(messages)
+	assertMessages(t, data, 1, 2)
+}
+
+func TestDisconnect(t *testing.T) {
+	b := New[int](WithBufferSize(2), WithPolicy(Disconnect))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slow := subscribe(t, ctx, b, "chat")
+	push(t, b, 1, 2, 3)
+
+	data, ok := drain(slow)
+	assertMessages(t, data, 1, 2)
+	if ok {
+		t.Fatal("the slow subscriber wasn't disconnected")
+	}
+
+	// the disconnected subscriber no longer holds the channel
+	fresh := subscribe(t, ctx, b, "chat")
+	push(t, b, 4)
+	data, _ = drain(fresh)
+	assertMessages(t, data, 4)
+}
+
+func TestInvalidOptionsKeepDefaults(t *testing.T) {
+	b := New[int](WithBufferSize(0), WithBufferSize(-1), WithPolicy(Policy(-1)), WithPolicy(Disconnect+1))
+	if b.config.bufferSize != DefaultBufferSize || b.config.policy != Block {
+		t.Fatalf("got %+v", b.config)
+	}
+}
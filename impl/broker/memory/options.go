@@ -0,0 +1,43 @@
+package memory
+
+const DefaultBufferSize = 512
+
+// Policy decides what happens when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// Block waits until the subscriber frees a slot or the push context is done.
+	Block Policy = iota
+	// DropOldest discards the oldest buffered message to make room for the new one.
+	DropOldest
+	// DropNewest discards the message being pushed.
+	DropNewest
+	// Disconnect closes the subscriber's channel and removes all its subscriptions.
+	Disconnect
+)
+
+type config struct {
+	bufferSize int
+	policy     Policy
+}
+
+type Option func(*config)
+
+// WithBufferSize sets how many messages a subscriber may fall behind before the policy applies,
+// sizes below 1 keep the default.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		if size >= 1 {
+			c.bufferSize = size
+		}
+	}
+}
+
+// WithPolicy sets what happens when a subscriber's buffer is full, unknown policies keep the default.
+func WithPolicy(policy Policy) Option {
+	return func(c *config) {
+		if policy >= Block && policy <= Disconnect {
+			c.policy = policy
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sakura/core/broker"
+	"sync"
+)
+
+var ErrClosed = errors.New("pubsub is closed")
+
+type PubSub[T any] struct {
+	broker *Broker[T]
+
+	// guarded by broker.mu
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	output chan broker.Message[T]
+	done   chan struct{}
+	once   sync.Once
+
+	// mu serializes deliveries and closing of the output channel
+	mu     sync.Mutex
+	closed bool
+}
+
+func (p *PubSub[T]) Subscribe(ctx context.Context, channels ...string) error {
+	if p.isClosed() {
+		return ErrClosed
+	}
+	p.broker.subscribe(p, channels...)
+	return nil
+}
+
+func (p *PubSub[T]) Unsubscribe(ctx context.Context, channels ...string) error {
+	p.broker.unsubscribe(p, channels...)
+	return nil
+}
+
+func (p *PubSub[T]) PSubscribe(ctx context.Context, patterns ...string) error {
+	if p.isClosed() {
+		return ErrClosed
+	}
+	p.broker.psubscribe(p, patterns...)
+	return nil
+}
+
+func (p *PubSub[T]) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	p.broker.punsubscribe(p, patterns...)
+	return nil
+}
+
+func (p *PubSub[T]) Channel(ctx context.Context) (<-chan broker.Message[T], error) {
+	if p.isClosed() {
+		return nil, ErrClosed
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.close()
+		case <-p.done:
+		}
+	}()
+
+	return p.output, nil
+}
+
+func (p *PubSub[T]) Clear(ctx context.Context) error {
+	p.broker.unsubscribeAll(p)
+	return nil
+}
+
+// deliver reports false if the subscriber must be disconnected.
+func (p *PubSub[T]) deliver(ctx context.Context, message broker.Message[T]) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return true
+	}
+
+	switch p.broker.config.policy {
+	case DropNewest:
+		select {
+		case p.output <- message:
+		default:
+		}
+	case DropOldest:
+		for {
+			select {
+			case p.output <- message:
+				return true
+			default:
+			}
+			select {
+			case <-p.output:
+			default:
+			}
+		}
+	case Disconnect:
+		select {
+		case p.output <- message:
+		default:
+			return false
+		}
+	default:
+		select {
+		case p.output <- message:
+		case <-p.done:
+		case <-ctx.Done():
+		}
+	}
+
+	return true
+}
+
+func (p *PubSub[T]) close() {
+	p.once.Do(func() {
+		close(p.done)
+		p.broker.unsubscribeAll(p)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.closed = true
+		close(p.output)
+	})
+}
+
+func (p *PubSub[T]) isClosed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sakura/core/broker"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker fails pushes fast for the cooldown after threshold consecutive failures,
+// then lets a single push through to probe the broker: its success closes the circuit again.
+// Thresholds below 1 are treated as 1.
+func CircuitBreaker[T any](threshold int, cooldown time.Duration) broker.Middleware[T] {
+	if threshold < 1 {
+		threshold = 1
+	}
+	circuit := &circuit{threshold: threshold, cooldown: cooldown}
+
+	return broker.Middleware[T]{
+		Push: func(next broker.PushFunc[T]) broker.PushFunc[T] {
+			return func(ctx context.Context, channel string, message T) error {
+				if !circuit.allow(time.Now()) {
+					return ErrCircuitOpen
+				}
+				err := next(ctx, channel, message)
+				circuit.record(err == nil, time.Now())
+				return err
+			}
+		},
+	}
+}
+
+type circuit struct {
+	threshold int
+	cooldown  time.Duration
+
+	failures  int
+	openUntil time.Time
+	probing   bool
+	mu        sync.Mutex
+}
+
+func (circuit *circuit) allow(now time.Time) bool {
+	circuit.mu.Lock()
+	defer circuit.mu.Unlock()
+
+	if circuit.failures < circuit.threshold {
+		return true
+	}
+	if now.Before(circuit.openUntil) || circuit.probing {
+		return false
+	}
+	circuit.probing = true
+	return true
+}
+
+func (circuit *circuit) record(success bool, now time.Time) {
+	circuit.mu.Lock()
+	defer circuit.mu.Unlock()
+
+	circuit.probing = false
+	if success {
+		circuit.failures = 0
+		return
+	}
+	circuit.failures++
+	if circuit.failures >= circuit.threshold {
+		circuit.openUntil = now.Add(circuit.cooldown)
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"sakura/channels"
+	"sakura/core/broker"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ChannelMetrics struct {
+	Pushed   uint64
+	Failed   uint64
+	Received uint64
+}
+
+// Metrics counts pushes and received messages per label, a label names a channel or a group of them.
+// Labels nothing was counted for during the idle period are forgotten along with their counts.
+type Metrics[T any] struct {
+	label func(channel string) string
+	idle  time.Duration
+
+	labels map[string]*counters
+	swept  time.Time
+	mu     sync.RWMutex
+}
+
+type counters struct {
+	pushed   atomic.Uint64
+	failed   atomic.Uint64
+	received atomic.Uint64
+	touched  atomic.Int64
+}
+
+// NewMetrics creates metrics counting under the labels the label function maps channels to,
+// nil counts every channel on its own. Idle periods below 1 keep labels forever.
+func NewMetrics[T any](label func(channel string) string, idle time.Duration) *Metrics[T] {
+	if label == nil {
+		label = func(channel string) string { return channel }
+	}
+	return &Metrics[T]{
+		label:  label,
+		idle:   idle,
+		labels: map[string]*counters{},
+		swept:  time.Now(),
+		mu:     sync.RWMutex{},
+	}
+}
+
+// UsersTogether counts all users' channels under a single label and every other channel on its own,
+// so the number of labels doesn't grow with the number of users.
+func UsersTogether(channel string) string {
+	if strings.HasPrefix(channel, channels.FromUser("")) {
+		return channels.FromUser("*")
+	}
+	return channel
+}
+
+func (metrics *Metrics[T]) Middleware() broker.Middleware[T] {
+	return broker.Middleware[T]{
+		Push: func(next broker.PushFunc[T]) broker.PushFunc[T] {
+			return func(ctx context.Context, channel string, message T) error {
+				err := next(ctx, channel, message)
+				if err != nil {
+					metrics.counters(channel).failed.Add(1)
+				} else {
+					metrics.counters(channel).pushed.Add(1)
+				}
+				return err
+			}
+		},
+		Stream: func(ctx context.Context, messages <-chan broker.Message[T]) <-chan broker.Message[T] {
+			outputs := make(chan broker.Message[T], cap(messages))
+
+			go func() {
+				defer close(outputs)
+
+				for message := range messages {
+					metrics.counters(message.Channel).received.Add(1)
+					select {
+					case outputs <- message:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+
+			return outputs
+		},
+	}
+}
+
+// Snapshot returns the counts by label.
+func (metrics *Metrics[T]) Snapshot() map[string]ChannelMetrics {
+	metrics.sweep(time.Now())
+
+	metrics.mu.RLock()
+	defer metrics.mu.RUnlock()
+
+	snapshot := make(map[string]ChannelMetrics, len(metrics.labels))
+	for label, c := range metrics.labels {
+		snapshot[label] = ChannelMetrics{
+			Pushed:   c.pushed.Load(),
+			Failed:   c.failed.Load(),
+			Received: c.received.Load(),
+		}
+	}
+	return snapshot
+}
+
+func (metrics *Metrics[T]) counters(channel string) *counters {
+	now := time.Now()
+	label := metrics.label(channel)
+
+	metrics.mu.RLock()
+	c, ok := metrics.labels[label]
+	metrics.mu.RUnlock()
+	if !ok {
+		metrics.sweep(now)
+
+		metrics.mu.Lock()
+		if c, ok = metrics.labels[label]; !ok {
+			c = &counters{}
+			metrics.labels[label] = c
+		}
+		metrics.mu.Unlock()
+	}
+
+	c.touched.Store(now.UnixNano())
+	return c
+}
+
+// sweep forgets the labels that have been idle for the idle period, at most once per period.
+func (metrics *Metrics[T]) sweep(now time.Time) {
+	if metrics.idle < 1 {
+		return
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	if now.Sub(metrics.swept) < metrics.idle {
+		return
+	}
+	metrics.swept = now
+	for label, c := range metrics.labels {
+		if now.Sub(time.Unix(0, c.touched.Load())) >= metrics.idle {
+			delete(metrics.labels, label)
+		}
+	}
+}
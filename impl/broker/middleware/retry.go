@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sakura/core/broker"
+	"time"
+)
+
+// Retry makes up to attempts pushes in total, waiting backoff before the first retry
+// and twice as long before every next one. Rejections by other middlewares aren't retried.
+func Retry[T any](attempts int, backoff time.Duration) broker.Middleware[T] {
+	return broker.Middleware[T]{
+		Push: func(next broker.PushFunc[T]) broker.PushFunc[T] {
+			return func(ctx context.Context, channel string, message T) error {
+				delay := backoff
+				for attempt := 1; ; attempt++ {
+					err := next(ctx, channel, message)
+					if err == nil || attempt >= attempts || !retryable(err) {
+						return err
+					}
+
+					select {
+					case <-ctx.Done():
+						return err
+					case <-time.After(delay):
+					}
+					delay *= 2
+				}
+			}
+		},
+	}
+}
+
+func retryable(err error) bool {
+	return !errors.Is(err, ErrMessageTooLarge) &&
+		!errors.Is(err, ErrCircuitOpen) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sakura/core/broker"
+)
+
+var ErrMessageTooLarge = errors.New("message is too large")
+
+// MaxSize rejects pushes of messages larger than limit, size measures a message
+// (e.g. the length of its payload).
+func MaxSize[T any](limit int, size func(message T) int) broker.Middleware[T] {
+	return broker.Middleware[T]{
+		Push: func(next broker.PushFunc[T]) broker.PushFunc[T] {
+			return func(ctx context.Context, channel string, message T) error {
+				if size(message) > limit {
+					return ErrMessageTooLarge
+				}
+				return next(ctx, channel, message)
+			}
+		},
+	}
+}
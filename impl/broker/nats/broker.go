@@ -0,0 +1,305 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"hash/fnv"
+	"log"
+	"sakura/channels"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"strings"
+	"sync"
+)
+
+var (
+	_ broker.Broker[any]        = (*Broker[any])(nil)
+	_ broker.PatternPubSub[any] = (*PubSub[any])(nil)
+)
+
+type Broker[T any] struct {
+	conn   *nats.Conn
+	codec  codec.Binary[T]
+	config config
+}
+
+func New[T any](conn *nats.Conn, codec codec.Binary[T], opts ...Option) *Broker[T] {
+	cfg := config{
+		prefix:     DefaultPrefix,
+		bufferSize: DefaultBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Broker[T]{
+		conn:   conn,
+		codec:  codec,
+		config: cfg,
+	}
+}
+
+// Subject returns the subject the channel is published to.
+func (b *Broker[T]) Subject(channel string) string {
+	return b.subject(channel, false)
+}
+
+func (b *Broker[T]) Push(ctx context.Context, channel string, message T) error {
+	payload, err := b.codec.Encoder().Convert(message)
+	if err != nil {
+		return err
+	}
+
+	subject := b.subject(channel, false)
+	if b.durable(channel) {
+		_, err := b.config.jetStream.Publish(subject, payload, nats.Context(ctx))
+		return err
+	}
+	return b.conn.Publish(subject, payload)
+}
+
+func (b *Broker[T]) PubSub() broker.PubSub[T] {
+	return &PubSub[T]{
+		broker:        b,
+		messages:      make(chan *nats.Msg, b.config.bufferSize),
+		subscriptions: map[string]*nats.Subscription{},
+		durables:      map[string]string{},
+		tracked:       channels.NewSubscriptions(),
+		mu:            sync.Mutex{},
+	}
+}
+
+func (b *Broker[T]) durable(channel string) bool {
+	return b.config.jetStream != nil &&
+		strings.HasPrefix(channel, channels.FromTopic("")) &&
+		b.config.durable(channels.ParseTopic(channel))
+}
+
+// durableName names the node's consumer of the subject, consumer names can't contain dots.
+func (b *Broker[T]) durableName(subject string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(subject))
+	return fmt.Sprintf("%s_%x", escapeToken(b.config.node), hash.Sum64())
+}
+
+type PubSub[T any] struct {
+	broker *Broker[T]
+	// every subscription delivers into the same channel
+	messages      chan *nats.Msg
+	subscriptions map[string]*nats.Subscription
+	// streams of the subjects consumed through JetStream
+	durables map[string]string
+	// a message matching several subscriptions arrives through each of them,
+	// it's reported only when it comes through the owner of its channel
+	tracked *channels.Subscriptions
+	mu      sync.Mutex
+}
+
+func (p *PubSub[T]) Subscribe(ctx context.Context, channels ...string) error {
+	return p.subscribe(channels, false)
+}
+
+func (p *PubSub[T]) Unsubscribe(ctx context.Context, channels ...string) error {
+	return p.unsubscribe(channels, false)
+}
+
+// PSubscribe subscribes topic patterns with native NATS wildcards,
+// pattern subscriptions always use core NATS.
+func (p *PubSub[T]) PSubscribe(ctx context.Context, patterns ...string) error {
+	return p.subscribe(patterns, true)
+}
+
+func (p *PubSub[T]) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	return p.unsubscribe(patterns, true)
+}
+
+func (p *PubSub[T]) Clear(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tracked.Clear()
+	for subject, subscription := range p.subscriptions {
+		if err := p.drop(subject, subscription); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PubSub[T]) Channel(ctx context.Context) (<-chan broker.Message[T], error) {
+	outputs := make(chan broker.Message[T], p.broker.config.bufferSize)
+
+	go func() {
+		defer close(outputs)
+		defer p.stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case rawMessage := <-p.messages:
+				channel, err := p.broker.channel(rawMessage.Subject)
+				if err != nil {
+					log.Println("failed to parse the subject:", err)
+					continue
+				}
+				if !p.owns(channel, rawMessage.Sub) {
+					ack(rawMessage)
+					continue
+				}
+
+				message, err := p.broker.codec.Decoder().Convert(rawMessage.Data)
+				if err != nil {
+					log.Println("failed to decode the message:", err)
+					ack(rawMessage)
+					continue
+				}
+
+				select {
+				case outputs <- broker.Message[T]{Channel: channel, Data: message}:
+					ack(rawMessage)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return outputs, nil
+}
+
+func (p *PubSub[T]) subscribe(channels []string, pattern bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, channel := range channels {
+		subject := p.broker.subject(channel, pattern)
+		if _, ok := p.subscriptions[subject]; ok {
+			continue
+		}
+
+		durable := !pattern && p.broker.durable(channel)
+
+		var stream string
+		var subscription *nats.Subscription
+		var err error
+		if durable {
+			stream, subscription, err = p.subscribeDurable(subject)
+		} else {
+			subscription, err = p.broker.conn.ChanSubscribe(subject, p.messages)
+		}
+		if err != nil {
+			return err
+		}
+		p.subscriptions[subject] = subscription
+		if durable {
+			p.durables[subject] = stream
+		}
+		p.tracked.Add(pattern, channel)
+	}
+	return nil
+}
+
+func (p *PubSub[T]) unsubscribe(channels []string, pattern bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, channel := range channels {
+		subject := p.broker.subject(channel, pattern)
+		subscription, ok := p.subscriptions[subject]
+		if !ok {
+			continue
+		}
+		if err := p.drop(subject, subscription); err != nil {
+			return err
+		}
+		p.tracked.Remove(pattern, channel)
+	}
+	return nil
+}
+
+// owns reports whether the subscription owns the channel.
+func (p *PubSub[T]) owns(channel string, subscription *nats.Subscription) bool {
+	owner, pattern, ok := p.tracked.Owner(channel)
+	if !ok {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.subscriptions[p.broker.subject(owner, pattern)] == subscription
+}
+
+// subscribeDurable binds to the node's durable consumer of the subject, creating it if needed.
+// The consumer is created here rather than by the subscription, so unsubscribing doesn't delete it.
+func (p *PubSub[T]) subscribeDurable(subject string) (string, *nats.Subscription, error) {
+	js := p.broker.config.jetStream
+	name := p.broker.durableName(subject)
+
+	stream, err := js.StreamNameBySubject(subject)
+	if err != nil {
+		return "", nil, err
+	}
+
+	_, err = js.ConsumerInfo(stream, name)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = js.AddConsumer(stream, &nats.ConsumerConfig{
+			Durable:        name,
+			DeliverSubject: nats.NewInbox(),
+			DeliverPolicy:  nats.DeliverNewPolicy,
+			AckPolicy:      nats.AckExplicitPolicy,
+			FilterSubject:  subject,
+		})
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	subscription, err := js.ChanSubscribe(subject, p.messages, nats.Bind(stream, name), nats.ManualAck())
+	return stream, subscription, err
+}
+
+// drop unsubscribes the subject for good, deleting its durable consumer.
+func (p *PubSub[T]) drop(subject string, subscription *nats.Subscription) error {
+	if err := subscription.Unsubscribe(); err != nil {
+		return err
+	}
+	if stream, ok := p.durables[subject]; ok {
+		err := p.broker.config.jetStream.DeleteConsumer(stream, p.broker.durableName(subject))
+		if err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+			return err
+		}
+	}
+	delete(p.subscriptions, subject)
+	delete(p.durables, subject)
+	return nil
+}
+
+// stop unsubscribes everything once nobody reads the messages.
+// Durable consumers are kept, so the node resumes them when it subscribes again.
+func (p *PubSub[T]) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.tracked.Clear()
+	for subject, subscription := range p.subscriptions {
+		if err := subscription.Unsubscribe(); err != nil {
+			log.Println("failed to unsubscribe:", err)
+		}
+		delete(p.subscriptions, subject)
+		delete(p.durables, subject)
+	}
+}
+
+// ack acknowledges JetStream messages, core NATS messages need no ack.
+func ack(message *nats.Msg) {
+	if _, err := message.Metadata(); err != nil {
+		return
+	}
+	if err := message.Ack(); err != nil {
+		log.Println("failed to ack the message:", err)
+	}
+}
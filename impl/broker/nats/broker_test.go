@@ -0,0 +1,259 @@
+package nats
+
+import (
+	"context"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"testing"
+	"time"
+)
+
+var stringCodec = codec.New(
+	func(value string) ([]byte, error) { return []byte(value), nil },
+	func(payload []byte) (string, error) { return string(payload), nil },
+)
+
+// connect starts an embedded server with JetStream and connects to it.
+func connect(t *testing.T) *nats.Conn {
+	srv, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		NoLog:     true,
+		NoSigs:    true,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Start()
+	t.Cleanup(srv.Shutdown)
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server isn't ready")
+	}
+
+	conn, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func subscribe(t *testing.T, ctx context.Context, b *Broker[string], channels, patterns []string) <-chan broker.Message[string] {
+	t.Helper()
+
+	pubsub := b.PubSub().(broker.PatternPubSub[string])
+	if err := pubsub.Subscribe(ctx, channels...); err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.PSubscribe(ctx, patterns...); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func push(t *testing.T, b *Broker[string], channel string, messages ...string) {
+	t.Helper()
+
+	for _, message := range messages {
+		if err := b.Push(context.Background(), channel, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// receive waits for the messages, in any order, and checks that no other message comes.
+func receive(t *testing.T, messages <-chan broker.Message[string], want ...broker.Message[string]) {
+	t.Helper()
+
+	missing := map[broker.Message[string]]int{}
+	for _, message := range want {
+		missing[message]++
+	}
+	for range want {
+		select {
+		case message := <-messages:
+			if missing[message] == 0 {
+				t.Fatalf("unexpected %+v", message)
+			}
+			missing[message]--
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v", missing)
+		}
+	}
+	select {
+	case message := <-messages:
+		t.Fatalf("unexpected %+v", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSubjects(t *testing.T) {
+	b := New[string](nil, stringCodec)
+
+	tests := []struct {
+		channel string
+		subject string
+	}{
+		{"user/alice", "sakura.user.alice"},
+		{"user/a.b c", "sakura.user.a%2Eb%20c"},
+		{"topic/chat.room-1", "sakura.topic.chat.room-1"},
+		{"topic/chat..x", "sakura.topic.chat.%.x"},
+		{"presence", "sakura.channel.presence"},
+	}
+	for _, test := range tests {
+		t.Run(test.channel, func(t *testing.T) {
+			subject := b.Subject(test.channel)
+			if subject != test.subject {
+				t.Fatalf("got subject %q, want %q", subject, test.subject)
+			}
+			channel, err := b.channel(subject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if channel != test.channel {
+				t.Fatalf("got channel %q back, want %q", channel, test.channel)
+			}
+		})
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	b := New[string](connect(t), stringCodec)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := subscribe(t, ctx, b, []string{"user/alice", "topic/chat"}, nil)
+	push(t, b, "user/bob", "skipped")
+	push(t, b, "topic/chat.room", "skipped")
+	push(t, b, "user/alice", "direct")
+	push(t, b, "topic/chat", "1", "2")
+
+	receive(t, messages,
+		broker.Message[string]{Channel: "user/alice", Data: "direct"},
+		broker.Message[string]{Channel: "topic/chat", Data: "1"},
+		broker.Message[string]{Channel: "topic/chat", Data: "2"},
+	)
+}
+
+func TestPatterns(t *testing.T) {
+	b := New[string](connect(t), stringCodec)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := subscribe(t, ctx, b, nil, []string{"topic/chat.*", "topic/orders.>"})
+	push(t, b, "topic/chat", "skipped")
+	push(t, b, "topic/chat.room.x", "skipped")
+	push(t, b, "topic/chat.room", "single")
+	push(t, b, "topic/orders", "skipped")
+	push(t, b, "topic/orders.eu.paid", "multi")
+
+	receive(t, messages,
+		broker.Message[string]{Channel: "topic/chat.room", Data: "single"},
+		broker.Message[string]{Channel: "topic/orders.eu.paid", Data: "multi"},
+	)
+}
+
+func TestOverlappingSubscriptionsDeliverOnce(t *testing.T) {
+	b := New[string](connect(t), stringCodec)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := subscribe(t, ctx, b, []string{"topic/chat.room"}, []string{"topic/chat.*", "topic/chat.>"})
+	push(t, b, "topic/chat.room", "direct and patterns")
+	push(t, b, "topic/chat.lobby", "two patterns")
+	push(t, b, "topic/chat.lobby.x", "one pattern")
+
+	receive(t, messages,
+		broker.Message[string]{Channel: "topic/chat.room", Data: "direct and patterns"},
+		broker.Message[string]{Channel: "topic/chat.lobby", Data: "two patterns"},
+		broker.Message[string]{Channel: "topic/chat.lobby.x", Data: "one pattern"},
+	)
+}
+
+func TestDurableConsumerResumes(t *testing.T) {
+	conn := connect(t)
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{Name: "SAKURA", Subjects: []string{"sakura.topic.orders.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	durable := func(topic string) bool { return topic != "chat" }
+	b := New[string](conn, stringCodec, WithJetStream(js, "node-1", durable))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := subscribe(t, ctx, b, []string{"topic/orders.eu", "topic/chat"}, nil)
+	push(t, b, "topic/orders.eu", "before")
+	push(t, b, "topic/chat", "before")
+	receive(t, messages,
+		broker.Message[string]{Channel: "topic/orders.eu", Data: "before"},
+		broker.Message[string]{Channel: "topic/chat", Data: "before"},
+	)
+	cancel()
+	for range messages {
+	}
+
+	push(t, b, "topic/orders.eu", "while down")
+	push(t, b, "topic/chat", "while down")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	messages = subscribe(t, ctx, b, []string{"topic/orders.eu", "topic/chat"}, nil)
+	receive(t, messages, broker.Message[string]{Channel: "topic/orders.eu", Data: "while down"})
+}
+
+func TestUnsubscribeDeletesDurableConsumer(t *testing.T) {
+	conn := connect(t)
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{Name: "SAKURA", Subjects: []string{"sakura.topic.>"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New[string](conn, stringCodec, WithJetStream(js, "node-1", func(string) bool { return true }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pubsub := b.PubSub()
+	if err := pubsub.Subscribe(ctx, "topic/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.Unsubscribe(ctx, "topic/orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := js.ConsumerInfo("SAKURA", b.durableName(b.Subject("topic/orders"))); err != nats.ErrConsumerNotFound {
+		t.Fatalf("got %v, want %v", err, nats.ErrConsumerNotFound)
+	}
+}
+
+func TestJetStreamRequiresNode(t *testing.T) {
+	js, err := connect(t).JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New[string](nil, stringCodec, WithJetStream(js, "", func(string) bool { return true }))
+	if b.durable("topic/orders") {
+		t.Fatal("got a durable topic without a node name")
+	}
+}
+
+func TestInvalidBufferSizeKeepsDefault(t *testing.T) {
+	b := New[string](nil, stringCodec, WithBufferSize(0), WithBufferSize(-1))
+	if b.config.bufferSize != DefaultBufferSize {
+		t.Fatalf("got buffer size %d, want %d", b.config.bufferSize, DefaultBufferSize)
+	}
+}
@@ -0,0 +1,52 @@
+package nats
+
+import "github.com/nats-io/nats.go"
+
+const (
+	DefaultPrefix     = "sakura"
+	DefaultBufferSize = 512
+)
+
+type config struct {
+	prefix     string
+	bufferSize int
+
+	jetStream nats.JetStreamContext
+	durable   func(topic string) bool
+	node      string
+}
+
+type Option func(*config)
+
+// WithPrefix sets the first token of every subject used by the broker.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithBufferSize sets the capacity of the channels returned by PubSub.Channel,
+// the same number of received messages may wait for decoding. Sizes below 1 keep the default.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		if size >= 1 {
+			c.bufferSize = size
+		}
+	}
+}
+
+// WithJetStream publishes the topics selected by the predicate through JetStream
+// and consumes them with a durable consumer per node and subject, so a node that was down
+// gets the messages it missed. A stream capturing the subjects of those topics (see Broker.Subject)
+// must exist. The node name must be unique and stable across restarts: container hostnames usually aren't,
+// use e.g. a StatefulSet pod name. The option is ignored if the node name is empty.
+func WithJetStream(js nats.JetStreamContext, node string, durable func(topic string) bool) Option {
+	return func(c *config) {
+		if node == "" {
+			return
+		}
+		c.jetStream = js
+		c.durable = durable
+		c.node = node
+	}
+}
@@ -0,0 +1,108 @@
+package nats
+
+import (
+	"fmt"
+	"sakura/channels"
+	"strconv"
+	"strings"
+)
+
+// Channels are mapped to subjects as follows:
+//
+//	user/<id>         -> <prefix>.user.<id as a single token>
+//	topic/<a.b.c>     -> <prefix>.topic.<a>.<b>.<c>
+//	anything else     -> <prefix>.channel.<channel as a single token>
+//
+// Topic tokens become subject tokens, so topic patterns map to native NATS wildcards.
+// Characters that aren't letters, digits, '-' or '_' are escaped as %XX, an empty token is "%".
+const (
+	userKind    = "user"
+	topicKind   = "topic"
+	channelKind = "channel"
+)
+
+func (b *Broker[T]) subject(channel string, pattern bool) string {
+	switch {
+	case strings.HasPrefix(channel, channels.FromUser("")):
+		return b.config.prefix + "." + userKind + "." + escapeToken(channels.ParseUser(channel))
+	case strings.HasPrefix(channel, channels.FromTopic("")):
+		tokens := strings.Split(channels.ParseTopic(channel), channels.Separator)
+		for i, token := range tokens {
+			if pattern && (token == channels.SingleWildcard || token == channels.MultiWildcard && i == len(tokens)-1) {
+				continue
+			}
+			tokens[i] = escapeToken(token)
+		}
+		return b.config.prefix + "." + topicKind + "." + strings.Join(tokens, ".")
+	default:
+		return b.config.prefix + "." + channelKind + "." + escapeToken(channel)
+	}
+}
+
+func (b *Broker[T]) channel(subject string) (string, error) {
+	if !strings.HasPrefix(subject, b.config.prefix+".") {
+		return "", fmt.Errorf("unexpected subject: %q", subject)
+	}
+	kind, tokens, _ := strings.Cut(strings.TrimPrefix(subject, b.config.prefix+"."), ".")
+
+	switch kind {
+	case userKind:
+		id, err := unescapeToken(tokens)
+		return channels.FromUser(id), err
+	case topicKind:
+		parts := strings.Split(tokens, ".")
+		for i, part := range parts {
+			token, err := unescapeToken(part)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = token
+		}
+		return channels.FromTopic(strings.Join(parts, channels.Separator)), nil
+	case channelKind:
+		return unescapeToken(tokens)
+	default:
+		return "", fmt.Errorf("unexpected subject: %q", subject)
+	}
+}
+
+func escapeToken(token string) string {
+	if token == "" {
+		return "%"
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' {
+			builder.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", c)
+	}
+	return builder.String()
+}
+
+func unescapeToken(token string) (string, error) {
+	if token == "%" {
+		return "", nil
+	}
+
+	var builder strings.Builder
+	for i := 0; i < len(token); i++ {
+		if token[i] != '%' {
+			builder.WriteByte(token[i])
+			continue
+		}
+		if i+2 >= len(token) {
+			return "", fmt.Errorf("malformed subject token: %q", token)
+		}
+		c, err := strconv.ParseUint(token[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("malformed subject token: %q", token)
+		}
+		builder.WriteByte(byte(c))
+		i += 2
+	}
+	return builder.String(), nil
+}
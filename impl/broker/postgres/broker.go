@@ -0,0 +1,244 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"log"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+)
+
+var _ broker.Broker[any] = (*Broker[any])(nil)
+
+// payloadLimit is the maximum size of a notification payload in Postgres (exclusive).
+const payloadLimit = 8000
+
+// notification is the payload of NOTIFY. Postgres channel names are hashes,
+// so the Sakura channel travels with the data. Data too large for a notification
+// is stored in the spill table and referenced by its row id.
+type notification struct {
+	Channel string `json:"c"`
+	Data    []byte `json:"d,omitempty"`
+	Ref     int64  `json:"r,omitempty"`
+}
+
+type Broker[T any] struct {
+	db     *sql.DB
+	dsn    string
+	codec  codec.Binary[T]
+	config config
+}
+
+// New creates a broker publishing through db. Every PubSub opens a dedicated listener connection using dsn.
+func New[T any](db *sql.DB, dsn string, codec codec.Binary[T], opts ...Option) *Broker[T] {
+	cfg := config{
+		prefix:               DefaultPrefix,
+		table:                DefaultTable,
+		bufferSize:           DefaultBufferSize,
+		spillRetention:       DefaultSpillRetention,
+		minReconnectInterval: DefaultMinReconnectInterval,
+		maxReconnectInterval: DefaultMaxReconnectInterval,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Broker[T]{
+		db:     db,
+		dsn:    dsn,
+		codec:  codec,
+		config: cfg,
+	}
+}
+
+// Migrate creates the spill table if it doesn't exist.
+func (b *Broker[T]) Migrate(ctx context.Context) error {
+	table := pq.QuoteIdentifier(b.config.table)
+	_, err := b.db.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id BIGSERIAL PRIMARY KEY,
+			payload BYTEA NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`, table))
+	if err != nil {
+		return err
+	}
+	_, err = b.db.ExecContext(ctx, fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS %s ON %s (created_at)`,
+		pq.QuoteIdentifier(b.config.table+"_created_at"), table,
+	))
+	return err
+}
+
+func (b *Broker[T]) Push(ctx context.Context, channel string, message T) error {
+	data, err := b.codec.Encoder().Convert(message)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(notification{Channel: channel, Data: data})
+	if err != nil {
+		return err
+	}
+	if len(payload) >= payloadLimit {
+		if payload, err = b.spill(ctx, channel, data); err != nil {
+			return err
+		}
+	}
+
+	_, err = b.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, b.channel(channel), string(payload))
+	return err
+}
+
+func (b *Broker[T]) PubSub() broker.PubSub[T] {
+	listener := pq.NewListener(b.dsn, b.config.minReconnectInterval, b.config.maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventConnectionAttemptFailed, pq.ListenerEventDisconnected:
+			log.Println("postgres listener connection failed:", err)
+		case pq.ListenerEventReconnected:
+			// the listener re-LISTENs its channels itself, but notifications sent in between are lost
+			log.Println("postgres listener reconnected")
+		}
+	})
+
+	return &PubSub[T]{
+		broker:   b,
+		listener: listener,
+	}
+}
+
+// spill stores the data in the spill table and returns a notification referencing it.
+// Expired payloads are removed along the way.
+func (b *Broker[T]) spill(ctx context.Context, channel string, data []byte) ([]byte, error) {
+	table := pq.QuoteIdentifier(b.config.table)
+
+	var id int64
+	err := b.db.QueryRowContext(ctx, fmt.Sprintf(`INSERT INTO %s (payload) VALUES ($1) RETURNING id`, table), data).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = b.db.ExecContext(ctx,
+		fmt.Sprintf(`DELETE FROM %s WHERE created_at < now() - make_interval(secs => $1)`, table),
+		b.config.spillRetention.Seconds(),
+	)
+	if err != nil {
+		log.Println("failed to remove expired spilled payloads:", err)
+	}
+
+	return json.Marshal(notification{Channel: channel, Ref: id})
+}
+
+func (b *Broker[T]) unspill(ctx context.Context, id int64) ([]byte, error) {
+	var data []byte
+	err := b.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT payload FROM %s WHERE id = $1`, pq.QuoteIdentifier(b.config.table)),
+		id,
+	).Scan(&data)
+	return data, err
+}
+
+// channel maps the Sakura channel to a Postgres channel name,
+// hashing keeps names within the identifier length limit whatever the Sakura channel is.
+func (b *Broker[T]) channel(channel string) string {
+	hash := sha256.Sum256([]byte(channel))
+	return b.config.prefix + hex.EncodeToString(hash[:20])
+}
+
+type PubSub[T any] struct {
+	broker   *Broker[T]
+	listener *pq.Listener
+}
+
+func (p *PubSub[T]) Subscribe(ctx context.Context, channels ...string) error {
+	for _, channel := range channels {
+		err := p.listener.Listen(p.broker.channel(channel))
+		if err != nil && !errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PubSub[T]) Unsubscribe(ctx context.Context, channels ...string) error {
+	for _, channel := range channels {
+		err := p.listener.Unlisten(p.broker.channel(channel))
+		if err != nil && !errors.Is(err, pq.ErrChannelNotOpen) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PubSub[T]) Clear(ctx context.Context) error {
+	return p.listener.UnlistenAll()
+}
+
+// Channel delivers the notifications until the context is done, then the listener is closed.
+func (p *PubSub[T]) Channel(ctx context.Context) (<-chan broker.Message[T], error) {
+	outputs := make(chan broker.Message[T], p.broker.config.bufferSize)
+
+	go func() {
+		defer close(outputs)
+		defer func() {
+			if err := p.listener.Close(); err != nil {
+				log.Println("failed to close the postgres listener:", err)
+			}
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case rawMessage, ok := <-p.listener.NotificationChannel():
+				if !ok {
+					return
+				}
+				// the listener sends nil after re-establishing the connection
+				if rawMessage == nil {
+					continue
+				}
+
+				message, channel, err := p.decode(ctx, rawMessage)
+				if err != nil {
+					log.Println("failed to decode the message:", err)
+					continue
+				}
+
+				select {
+				case outputs <- broker.Message[T]{Channel: channel, Data: message}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return outputs, nil
+}
+
+func (p *PubSub[T]) decode(ctx context.Context, rawMessage *pq.Notification) (T, string, error) {
+	var zero T
+
+	var n notification
+	if err := json.Unmarshal([]byte(rawMessage.Extra), &n); err != nil {
+		return zero, "", err
+	}
+
+	data := n.Data
+	if n.Ref != 0 {
+		var err error
+		if data, err = p.broker.unspill(ctx, n.Ref); err != nil {
+			return zero, "", fmt.Errorf("failed to fetch a spilled payload: %w", err)
+		}
+	}
+
+	message, err := p.broker.codec.Decoder().Convert(data)
+	return message, n.Channel, err
+}
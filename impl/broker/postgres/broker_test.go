@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"sakura/internal/testenv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stringCodec = codec.New(
+	func(value string) ([]byte, error) { return []byte(value), nil },
+	func(payload []byte) (string, error) { return string(payload), nil },
+)
+
+// newBroker connects to the database in testenv.PostgresDSN with a fresh spill table.
+func newBroker(t *testing.T, opts ...Option) *Broker[string] {
+	dsn := testenv.DSN(t, testenv.PostgresDSN)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	b := New[string](db, dsn, stringCodec, opts...)
+	if _, err := db.Exec(`DROP TABLE IF EXISTS ` + pq.QuoteIdentifier(b.config.table)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func subscribe(t *testing.T, ctx context.Context, b *Broker[string], channels ...string) <-chan broker.Message[string] {
+	t.Helper()
+
+	pubsub := b.PubSub()
+	if err := pubsub.Subscribe(ctx, channels...); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func receive(t *testing.T, messages <-chan broker.Message[string], want broker.Message[string]) {
+	t.Helper()
+
+	select {
+	case message := <-messages:
+		if message != want {
+			t.Fatalf("got %.40q on %s, want %.40q on %s", message.Data, message.Channel, want.Data, want.Channel)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %.40q", want.Data)
+	}
+}
+
+func spilled(t *testing.T, b *Broker[string]) int {
+	t.Helper()
+
+	var count int
+	if err := b.db.QueryRow(`SELECT count(*) FROM ` + pq.QuoteIdentifier(b.config.table)).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestChannelNames(t *testing.T) {
+	b := New[string](nil, "", stringCodec)
+
+	tests := []string{"", "user/alice", "topic/chat.room", strings.Repeat("topic/very.long.", 100)}
+	names := map[string]string{}
+	for _, channel := range tests {
+		name := b.channel(channel)
+		if len(name) > 63 {
+			t.Fatalf("%q: name %q exceeds the identifier limit", channel, name)
+		}
+		if !strings.HasPrefix(name, DefaultPrefix) {
+			t.Fatalf("%q: name %q lacks the prefix", channel, name)
+		}
+		if name != b.channel(channel) {
+			t.Fatalf("%q: names differ between calls", channel)
+		}
+		if other, ok := names[name]; ok {
+			t.Fatalf("%q and %q share the name %q", channel, other, name)
+		}
+		names[name] = channel
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	b := newBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := subscribe(t, ctx, b, "topic/chat")
+	for _, channel := range []string{"topic/news", "topic/chat"} {
+		if err := b.Push(ctx, channel, "hi "+channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	receive(t, messages, broker.Message[string]{Channel: "topic/chat", Data: "hi topic/chat"})
+}
+
+func TestSpill(t *testing.T) {
+	b := newBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := subscribe(t, ctx, b, "topic/chat")
+
+	tests := []struct {
+		name    string
+		size    int
+		spilled int
+	}{
+		// base64 makes the notification a third larger than the data
+		{"small", 1000, 0},
+		{"over the limit", payloadLimit, 1},
+		{"large", 1 << 20, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := spilled(t, b)
+			data := string(bytes.Repeat([]byte("s"), test.size))
+			if err := b.Push(ctx, "topic/chat", data); err != nil {
+				t.Fatal(err)
+			}
+			receive(t, messages, broker.Message[string]{Channel: "topic/chat", Data: data})
+
+			if got := spilled(t, b) - before; got != test.spilled {
+				t.Fatalf("got %d spilled payloads, want %d", got, test.spilled)
+			}
+		})
+	}
+}
+
+func TestSpillRetention(t *testing.T) {
+	retention := 200 * time.Millisecond
+	b := newBroker(t, WithSpillRetention(retention))
+	data := string(bytes.Repeat([]byte("s"), payloadLimit))
+
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(retention + 100*time.Millisecond)
+		}
+		if err := b.Push(context.Background(), "topic/chat", data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := spilled(t, b); got != 1 {
+		t.Fatalf("got %d spilled payloads, want the expired one removed", got)
+	}
+}
+
+// TestRelisten checks that the listener subscribes its channels again after losing its connection.
+func TestRelisten(t *testing.T) {
+	b := newBroker(t, WithReconnectInterval(10*time.Millisecond, 100*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := subscribe(t, ctx, b, "topic/chat")
+
+	if err := b.Push(ctx, "topic/chat", "before"); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, messages, broker.Message[string]{Channel: "topic/chat", Data: "before"})
+
+	_, err := b.db.Exec(`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query LIKE 'LISTEN %' AND pid <> pg_backend_pid()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// notifications sent while the listener reconnects are lost, so pushes repeat until one arrives
+	deadline := time.After(5 * time.Second)
+	for i := 0; ; i++ {
+		data := fmt.Sprint("after ", i)
+		if err := b.Push(ctx, "topic/chat", data); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case message := <-messages:
+			if message.Channel != "topic/chat" || !strings.HasPrefix(message.Data, "after ") {
+				t.Fatalf("got %+v", message)
+			}
+			return
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("the listener didn't listen again")
+		}
+	}
+}
+
+func TestNegativeBufferSizeKeepsDefault(t *testing.T) {
+	b := New[string](nil, "", stringCodec, WithBufferSize(-1))
+	if b.config.bufferSize != DefaultBufferSize {
+		t.Fatalf("got buffer size %d, want %d", b.config.bufferSize, DefaultBufferSize)
+	}
+}
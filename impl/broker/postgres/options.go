@@ -0,0 +1,63 @@
+package postgres
+
+import "time"
+
+const (
+	DefaultPrefix               = "sakura_"
+	DefaultTable                = "sakura_notifications"
+	DefaultBufferSize           = 512
+	DefaultSpillRetention       = time.Minute
+	DefaultMinReconnectInterval = 100 * time.Millisecond
+	DefaultMaxReconnectInterval = 10 * time.Second
+)
+
+type config struct {
+	prefix               string
+	table                string
+	bufferSize           int
+	spillRetention       time.Duration
+	minReconnectInterval time.Duration
+	maxReconnectInterval time.Duration
+}
+
+type Option func(*config)
+
+// WithPrefix sets the prefix of the Postgres channel names, Sakura channels are hashed after it.
+// Together with the hash it must fit into 63 bytes.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithTable sets the table payloads too large for a notification are spilled into.
+func WithTable(table string) Option {
+	return func(c *config) {
+		c.table = table
+	}
+}
+
+// WithBufferSize sets the capacity of the channels returned by PubSub.Channel,
+// negative sizes keep the default.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		if size >= 0 {
+			c.bufferSize = size
+		}
+	}
+}
+
+// WithSpillRetention sets how long spilled payloads are kept for the listeners to fetch them.
+func WithSpillRetention(retention time.Duration) Option {
+	return func(c *config) {
+		c.spillRetention = retention
+	}
+}
+
+// WithReconnectInterval sets the bounds of the backoff between attempts to re-establish a lost listener connection.
+func WithReconnectInterval(min, max time.Duration) Option {
+	return func(c *config) {
+		c.minReconnectInterval = min
+		c.maxReconnectInterval = max
+	}
+}
@@ -4,13 +4,36 @@ import (
 	"context"
 	"github.com/redis/go-redis/v9"
 	"log"
+	"sakura/channels"
 	"sakura/common/data/codec"
 	"sakura/core/broker"
+	"strings"
+)
+
+var (
+	_ broker.Broker[any]        = (*Broker[any])(nil)
+	_ broker.PatternPubSub[any] = (*PubSub[any])(nil)
 )
 
 type Broker[T any] struct {
 	client redis.UniversalClient
 	codec  codec.Binary[T]
+	config config
+}
+
+func New[T any](client redis.UniversalClient, codec codec.Binary[T], opts ...Option) *Broker[T] {
+	cfg := config{
+		bufferSize: DefaultBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Broker[T]{
+		client: client,
+		codec:  codec,
+		config: cfg,
+	}
 }
 
 func (b *Broker[T]) Push(ctx context.Context, channel string, message T) error {
@@ -18,63 +41,129 @@ func (b *Broker[T]) Push(ctx context.Context, channel string, message T) error {
 	if err != nil {
 		return err
 	}
-	return b.client.Publish(ctx, channel, payload).Err()
+	return b.client.Publish(ctx, b.config.prefix+channel, payload).Err()
 }
 
-func (b *Broker[T]) PubSub(ctx context.Context) broker.PubSub[T] {
-	pubsub := b.client.Subscribe(ctx)
+func (b *Broker[T]) PubSub() broker.PubSub[T] {
+	// Subscribing with no channels doesn't touch the network,
+	// the connection is established lazily on the first subscription.
+	pubsub := b.client.Subscribe(context.Background())
 	return &PubSub[T]{
-		pubsub: pubsub,
-		codec:  b.codec,
+		pubsub:        pubsub,
+		codec:         b.codec,
+		config:        b.config,
+		subscriptions: channels.NewSubscriptions(),
 	}
 }
 
+// PubSub reports every message once: Redis sends a message once per matching subscription,
+// the copies that came through other subscriptions than the owner of the channel are dropped.
 type PubSub[T any] struct {
-	pubsub *redis.PubSub
-	codec  codec.Binary[T]
+	pubsub        *redis.PubSub
+	codec         codec.Binary[T]
+	config        config
+	subscriptions *channels.Subscriptions
 }
 
 func (p *PubSub[T]) Subscribe(ctx context.Context, channels ...string) error {
-	if len(channels) == 0 {
+	fresh := p.subscriptions.Add(false, channels...)
+	if len(fresh) == 0 {
 		return nil
 	}
-	return p.pubsub.Subscribe(ctx, channels...)
+	if err := p.pubsub.Subscribe(ctx, p.withPrefix(fresh)...); err != nil {
+		p.subscriptions.Remove(false, fresh...)
+		return err
+	}
+	return nil
 }
 
 func (p *PubSub[T]) Unsubscribe(ctx context.Context, channels ...string) error {
-	if len(channels) == 0 {
+	stale := p.subscriptions.Remove(false, channels...)
+	if len(stale) == 0 {
 		return nil
 	}
-	return p.pubsub.Unsubscribe(ctx, channels...)
+	return p.pubsub.Unsubscribe(ctx, p.withPrefix(stale)...)
+}
+
+// PSubscribe translates Sakura patterns into Redis glob patterns. Globs can't express
+// token boundaries, so they may match more channels than the original pattern,
+// messages of those channels are dropped.
+func (p *PubSub[T]) PSubscribe(ctx context.Context, patterns ...string) error {
+	fresh := p.subscriptions.Add(true, patterns...)
+	if len(fresh) == 0 {
+		return nil
+	}
+	if err := p.pubsub.PSubscribe(ctx, p.withPrefix(toGlobs(fresh))...); err != nil {
+		p.subscriptions.Remove(true, fresh...)
+		return err
+	}
+	return nil
+}
+
+// PUnsubscribe keeps the globs that other subscribed patterns translate into as well.
+func (p *PubSub[T]) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	stale := p.subscriptions.Remove(true, patterns...)
+
+	used := map[string]struct{}{}
+	for _, glob := range toGlobs(p.subscriptions.Patterns()) {
+		used[glob] = struct{}{}
+	}
+	var globs []string
+	for _, glob := range toGlobs(stale) {
+		if _, ok := used[glob]; !ok {
+			globs = append(globs, glob)
+		}
+	}
+
+	if len(globs) == 0 {
+		return nil
+	}
+	return p.pubsub.PUnsubscribe(ctx, p.withPrefix(globs)...)
 }
 
 func (p *PubSub[T]) Channel(ctx context.Context) (<-chan broker.Message[T], error) {
-	messages := p.pubsub.Channel()
-	outputs := make(chan broker.Message[T], 512)
+	var channelOptions []redis.ChannelOption
+	if p.config.healthCheckInterval > 0 {
+		channelOptions = append(channelOptions, redis.WithChannelHealthCheckInterval(p.config.healthCheckInterval))
+	}
+	if p.config.sendTimeout > 0 {
+		channelOptions = append(channelOptions, redis.WithChannelSendTimeout(p.config.sendTimeout))
+	}
 
-	go func(ctx context.Context, ch chan<- broker.Message[T]) {
-		<-ctx.Done()
-		close(ch)
-	}(ctx, outputs)
+	messages := p.pubsub.Channel(channelOptions...)
+	outputs := make(chan broker.Message[T], p.config.bufferSize)
 
 	go func(ctx context.Context, from <-chan *redis.Message, to chan<- broker.Message[T]) {
-	loop:
+		defer close(to)
+
 		for {
 			select {
 			case <-ctx.Done():
-				break loop
+				return
+
+			case rawMessage, ok := <-from:
+				if !ok {
+					return
+				}
 
-			case rawMessage := <-messages:
-				payload := []byte(rawMessage.Payload)
-				message, err := p.codec.Decoder().Convert(payload)
+				channel := strings.TrimPrefix(rawMessage.Channel, p.config.prefix)
+				if !p.owns(channel, rawMessage.Pattern) {
+					continue
+				}
+
+				message, err := p.codec.Decoder().Convert([]byte(rawMessage.Payload))
 				if err != nil {
 					log.Println("failed to decode the message:", err)
 					continue
 				}
 
-				to <- broker.Message[T]{
-					Channel: rawMessage.Channel,
-					Data:    []byte(message),
+				select {
+				case to <- broker.Message[T]{
+					Channel: channel,
+					Data:    message,
+				}:
+				case <-ctx.Done():
+					return
 				}
 			}
 		}
@@ -83,6 +172,31 @@ func (p *PubSub[T]) Channel(ctx context.Context) (<-chan broker.Message[T], erro
 	return outputs, nil
 }
 
+// owns reports whether the subscription the message came through, a glob or the channel itself
+// if the glob is empty, owns the channel.
+func (p *PubSub[T]) owns(channel, glob string) bool {
+	owner, pattern, ok := p.subscriptions.Owner(channel)
+	if !ok || pattern != (glob != "") {
+		return false
+	}
+	return !pattern || p.config.prefix+toGlob(owner) == glob
+}
+
 func (p *PubSub[T]) Clear(ctx context.Context) error {
-	return p.pubsub.Unsubscribe(ctx)
+	p.subscriptions.Clear()
+	if err := p.pubsub.Unsubscribe(ctx); err != nil {
+		return err
+	}
+	return p.pubsub.PUnsubscribe(ctx)
+}
+
+func (p *PubSub[T]) withPrefix(channels []string) []string {
+	if p.config.prefix == "" {
+		return channels
+	}
+	prefixed := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		prefixed = append(prefixed, p.config.prefix+channel)
+	}
+	return prefixed
 }
@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"testing"
+	"time"
+)
+
+var stringCodec = codec.New(
+	func(value string) ([]byte, error) { return []byte(value), nil },
+	func(payload []byte) (string, error) { return string(payload), nil },
+)
+
+func newBroker(t *testing.T) *Broker[string] {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return New[string](client, stringCodec)
+}
+
+func subscribe(t *testing.T, ctx context.Context, b *Broker[string], channels, patterns []string) (*PubSub[string], <-chan broker.Message[string]) {
+	t.Helper()
+
+	pubsub := b.PubSub().(*PubSub[string])
+	if err := pubsub.Subscribe(ctx, channels...); err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.PSubscribe(ctx, patterns...); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pubsub, messages
+}
+
+func push(t *testing.T, b *Broker[string], channel string, messages ...string) {
+	t.Helper()
+
+	for _, message := range messages {
+		if err := b.Push(context.Background(), channel, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// receive waits for the messages in order and checks that no other message comes.
+func receive(t *testing.T, messages <-chan broker.Message[string], want ...broker.Message[string]) {
+	t.Helper()
+
+	for _, expected := range want {
+		select {
+		case message := <-messages:
+			if message != expected {
+				t.Fatalf("got %+v, want %+v", message, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %+v", expected)
+		}
+	}
+	select {
+	case message := <-messages:
+		t.Fatalf("unexpected %+v", message)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestOverlappingSubscriptionsDeliverOnce(t *testing.T) {
+	b := newBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, messages := subscribe(t, ctx, b,
+		[]string{"topic/chat.room"},
+		[]string{"topic/chat.*", "topic/chat.>", "topic/orders.*"},
+	)
+	push(t, b, "topic/chat.room", "direct and patterns")
+	push(t, b, "topic/chat.lobby", "two patterns")
+	push(t, b, "topic/chat.lobby.x", "one pattern")
+	// the glob of orders.* matches any depth, the pattern doesn't
+	push(t, b, "topic/orders.eu.paid", "too deep")
+
+	receive(t, messages,
+		broker.Message[string]{Channel: "topic/chat.room", Data: "direct and patterns"},
+		broker.Message[string]{Channel: "topic/chat.lobby", Data: "two patterns"},
+		broker.Message[string]{Channel: "topic/chat.lobby.x", Data: "one pattern"},
+	)
+}
+
+func TestPUnsubscribeKeepsSharedGlobs(t *testing.T) {
+	b := newBroker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pubsub, messages := subscribe(t, ctx, b, nil, []string{"topic/chat.*", "topic/chat.>"})
+	if err := pubsub.PUnsubscribe(ctx, "topic/chat.>"); err != nil {
+		t.Fatal(err)
+	}
+	push(t, b, "topic/chat.lobby.x", "unsubscribed")
+	push(t, b, "topic/chat.lobby", "subscribed")
+
+	receive(t, messages, broker.Message[string]{Channel: "topic/chat.lobby", Data: "subscribed"})
+}
+
+func TestNegativeBufferSizeKeepsDefault(t *testing.T) {
+	b := New[string](nil, stringCodec, WithBufferSize(-1))
+	if b.config.bufferSize != DefaultBufferSize {
+		t.Fatalf("got buffer size %d, want %d", b.config.bufferSize, DefaultBufferSize)
+	}
+}
@@ -0,0 +1,47 @@
+package redis
+
+import "time"
+
+const DefaultBufferSize = 512
+
+type config struct {
+	prefix              string
+	bufferSize          int
+	healthCheckInterval time.Duration
+	sendTimeout         time.Duration
+}
+
+type Option func(*config)
+
+// WithPrefix prepends the prefix to every channel name used in Redis.
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+// WithBufferSize sets the capacity of the channels returned by PubSub.Channel,
+// negative sizes keep the default.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		if size >= 0 {
+			c.bufferSize = size
+		}
+	}
+}
+
+// WithHealthCheckInterval sets how often an idle subscription connection is pinged.
+// A connection that fails the check is re-established and resubscribed.
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.healthCheckInterval = interval
+	}
+}
+
+// WithSendTimeout sets how long a received message may wait for a free slot
+// in the buffer before the subscription connection is considered broken and reconnected.
+func WithSendTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.sendTimeout = timeout
+	}
+}
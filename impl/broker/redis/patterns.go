@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"sakura/channels"
+	"strings"
+)
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func toGlobs(patterns []string) []string {
+	globs := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		globs = append(globs, toGlob(pattern))
+	}
+	return globs
+}
+
+// toGlob translates a topic channel pattern, the channel prefix isn't a part of the first token.
+func toGlob(pattern string) string {
+	prefix := ""
+	if strings.HasPrefix(pattern, channels.FromTopic("")) {
+		prefix, pattern = globEscaper.Replace(channels.FromTopic("")), channels.ParseTopic(pattern)
+	}
+
+	tokens := strings.Split(pattern, channels.Separator)
+	for i, token := range tokens {
+		switch token {
+		case channels.SingleWildcard, channels.MultiWildcard:
+			tokens[i] = "*"
+		default:
+			tokens[i] = globEscaper.Replace(token)
+		}
+	}
+	return prefix + strings.Join(tokens, channels.Separator)
+}
@@ -0,0 +1,331 @@
+package redisstream
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"sakura/channels"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	_ broker.Broker[any]        = (*Broker[any])(nil)
+	_ broker.PatternPubSub[any] = (*PubSub[any])(nil)
+)
+
+const (
+	channelField = "channel"
+	dataField    = "data"
+)
+
+// retryDelay is how long reading waits after a failure before it's retried.
+const retryDelay = time.Second
+
+var ErrConsuming = errors.New("another pubsub of the broker is consuming the node's group")
+
+// Broker multiplexes all channels into a single Redis stream.
+// Every node reads the whole stream through a consumer group named after the node and keeps the entries
+// of the channels it's subscribed to, so nothing is lost while a node is down: it resumes from its group's position.
+// The group has a single consumer, so only one pubsub of a broker may consume at a time.
+type Broker[T any] struct {
+	client redis.UniversalClient
+	codec  codec.Binary[T]
+	node   string
+	config config
+	// consuming is set while a pubsub's channel is open
+	consuming atomic.Bool
+}
+
+// New creates a broker for the node. The node id names the node's consumer group and must be unique
+// and stable across restarts: container hostnames usually aren't, use e.g. a StatefulSet pod name.
+// A node started under a new id gets a new group that starts at the end of the stream,
+// the group of the old id keeps the stream's entries pending until it's removed with ForgetNode.
+func New[T any](client redis.UniversalClient, codec codec.Binary[T], node string, opts ...Option) *Broker[T] {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Broker[T]{
+		client: client,
+		codec:  codec,
+		node:   node,
+		config: cfg,
+	}
+}
+
+// ForgetNode removes the consumer group of a node that won't come back.
+func (b *Broker[T]) ForgetNode(ctx context.Context, node string) error {
+	return b.client.XGroupDestroy(ctx, b.config.stream, node).Err()
+}
+
+func (b *Broker[T]) Push(ctx context.Context, channel string, message T) error {
+	payload, err := b.codec.Encoder().Convert(message)
+	if err != nil {
+		return err
+	}
+
+	args := &redis.XAddArgs{
+		Stream: b.config.stream,
+		Values: []string{channelField, channel, dataField, string(payload)},
+	}
+	if b.config.maxLen > 0 {
+		args.MaxLen = b.config.maxLen
+		args.Approx = true
+	}
+	return b.client.XAdd(ctx, args).Err()
+}
+
+func (b *Broker[T]) PubSub() broker.PubSub[T] {
+	return &PubSub[T]{
+		client:    b.client,
+		codec:     b.codec,
+		node:      b.node,
+		config:    b.config,
+		consuming: &b.consuming,
+		channels:  map[string]struct{}{},
+		patterns:  map[string]struct{}{},
+		mu:        sync.RWMutex{},
+	}
+}
+
+// PubSub filters the entries read by the node's consumer locally,
+// so subscribing doesn't touch Redis.
+type PubSub[T any] struct {
+	client redis.UniversalClient
+	codec  codec.Binary[T]
+	// the node's consumer group and its only consumer
+	node      string
+	config    config
+	consuming *atomic.Bool
+
+	channels map[string]struct{}
+	patterns map[string]struct{}
+	mu       sync.RWMutex
+}
+
+func (p *PubSub[T]) Subscribe(ctx context.Context, channels ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, channel := range channels {
+		p.channels[channel] = struct{}{}
+	}
+	return nil
+}
+
+func (p *PubSub[T]) Unsubscribe(ctx context.Context, channels ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, channel := range channels {
+		delete(p.channels, channel)
+	}
+	return nil
+}
+
+func (p *PubSub[T]) PSubscribe(ctx context.Context, patterns ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pattern := range patterns {
+		p.patterns[pattern] = struct{}{}
+	}
+	return nil
+}
+
+func (p *PubSub[T]) PUnsubscribe(ctx context.Context, patterns ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pattern := range patterns {
+		delete(p.patterns, pattern)
+	}
+	return nil
+}
+
+func (p *PubSub[T]) Clear(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.channels = map[string]struct{}{}
+	p.patterns = map[string]struct{}{}
+	return nil
+}
+
+// Channel starts consuming the stream. Entries are acked once they're handed to the returned channel
+// (or skipped as unsubscribed), entries that were read but not acked before a restart are delivered first.
+// It fails with ErrConsuming while the channel of another pubsub of the broker is open,
+// since both would split the entries of the node's group between them.
+func (p *PubSub[T]) Channel(ctx context.Context) (<-chan broker.Message[T], error) {
+	if !p.consuming.CompareAndSwap(false, true) {
+		return nil, ErrConsuming
+	}
+	if err := p.createGroup(ctx); err != nil {
+		p.consuming.Store(false)
+		return nil, err
+	}
+
+	outputs := make(chan broker.Message[T], p.config.bufferSize)
+	go p.consume(ctx, outputs)
+	return outputs, nil
+}
+
+func (p *PubSub[T]) consume(ctx context.Context, to chan<- broker.Message[T]) {
+	defer close(to)
+	defer p.consuming.Store(false)
+
+	if !p.readPending(ctx, to) {
+		return
+	}
+
+	for ctx.Err() == nil {
+		streams, err := p.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    p.node,
+			Consumer: p.node,
+			Streams:  []string{p.config.stream, ">"},
+			Count:    p.config.batchSize,
+			Block:    p.config.block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if !p.recover(ctx, err) {
+				return
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			if !p.dispatch(ctx, to, stream.Messages) {
+				return
+			}
+		}
+	}
+}
+
+// readPending delivers the entries the node read but didn't ack, e.g. before a crash.
+// A group has a single consumer, so there are no other consumers' entries to claim.
+func (p *PubSub[T]) readPending(ctx context.Context, to chan<- broker.Message[T]) bool {
+	start := "0"
+	for {
+		streams, err := p.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    p.node,
+			Consumer: p.node,
+			Streams:  []string{p.config.stream, start},
+			Count:    p.config.batchSize,
+			Block:    -1,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			return true
+		}
+		if err != nil {
+			if !p.recover(ctx, err) {
+				return false
+			}
+			continue
+		}
+		if len(streams) == 0 || len(streams[0].Messages) == 0 {
+			return true
+		}
+
+		messages := streams[0].Messages
+		if !p.dispatch(ctx, to, messages) {
+			return false
+		}
+		start = messages[len(messages)-1].ID
+	}
+}
+
+// dispatch hands the entries of subscribed channels over and acks the processed entries.
+func (p *PubSub[T]) dispatch(ctx context.Context, to chan<- broker.Message[T], messages []redis.XMessage) bool {
+	processed := make([]string, 0, len(messages))
+	defer func() {
+		if len(processed) == 0 {
+			return
+		}
+		// the subscription's context may be done already, but the handed over entries must be acked
+		err := p.client.XAck(context.Background(), p.config.stream, p.node, processed...).Err()
+		if err != nil {
+			log.Println("failed to ack stream entries:", err)
+		}
+	}()
+
+	for _, rawMessage := range messages {
+		// entries trimmed while pending come back without values
+		channel, _ := rawMessage.Values[channelField].(string)
+		payload, _ := rawMessage.Values[dataField].(string)
+		if channel == "" || !p.subscribed(channel) {
+			processed = append(processed, rawMessage.ID)
+			continue
+		}
+
+		message, err := p.codec.Decoder().Convert([]byte(payload))
+		if err != nil {
+			log.Println("failed to decode the message:", err)
+			processed = append(processed, rawMessage.ID)
+			continue
+		}
+
+		select {
+		case to <- broker.Message[T]{Channel: channel, Data: message}:
+			processed = append(processed, rawMessage.ID)
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+func (p *PubSub[T]) subscribed(channel string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if _, ok := p.channels[channel]; ok {
+		return true
+	}
+	for pattern := range p.patterns {
+		if channels.Match(pattern, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// recover reports whether reading may go on after the error.
+func (p *PubSub[T]) recover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	log.Println("failed to read the stream:", err)
+
+	// the group disappears if the stream is deleted
+	if strings.HasPrefix(err.Error(), "NOGROUP") {
+		if err := p.createGroup(ctx); err != nil {
+			log.Println("failed to create the consumer group:", err)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(retryDelay):
+		return true
+	}
+}
+
+// createGroup creates the node's consumer group, a new group starts with the entries added after its creation.
+func (p *PubSub[T]) createGroup(ctx context.Context) error {
+	err := p.client.XGroupCreateMkStream(ctx, p.config.stream, p.node, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package redisstream
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"sakura/common/data/codec"
+	"sakura/core/broker"
+	"testing"
+	"time"
+)
+
+var stringCodec = codec.New(
+	func(value string) ([]byte, error) { return []byte(value), nil },
+	func(payload []byte) (string, error) { return string(payload), nil },
+)
+
+func newClient(t *testing.T) redis.UniversalClient {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// newBroker blocks briefly so stopping a subscription doesn't hold the tests up.
+func newBroker(client redis.UniversalClient, node string) *Broker[string] {
+	return New[string](client, stringCodec, node, WithBlock(50*time.Millisecond))
+}
+
+func subscribe(t *testing.T, ctx context.Context, b *Broker[string], channels, patterns []string) <-chan broker.Message[string] {
+	t.Helper()
+
+	pubsub := b.PubSub().(*PubSub[string])
+	if err := pubsub.Subscribe(ctx, channels...); err != nil {
+		t.Fatal(err)
+	}
+	if err := pubsub.PSubscribe(ctx, patterns...); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := pubsub.Channel(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func push(t *testing.T, b *Broker[string], channel string, messages ...string) {
+	t.Helper()
+
+	for _, message := range messages {
+		if err := b.Push(context.Background(), channel, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// receive waits for the messages in order and checks that no other message comes.
+func receive(t *testing.T, messages <-chan broker.Message[string], want ...broker.Message[string]) {
+	t.Helper()
+
+	for _, expected := range want {
+		select {
+		case message := <-messages:
+			if message != expected {
+				t.Fatalf("got %+v, want %+v", message, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %+v", expected)
+		}
+	}
+	select {
+	case message := <-messages:
+		t.Fatalf("unexpected %+v", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// stop cancels the subscription and waits for its channel to close.
+func stop(cancel context.CancelFunc, messages <-chan broker.Message[string]) {
+	cancel()
+	for range messages {
+	}
+}
+
+func assertPending(t *testing.T, client redis.UniversalClient, node string, want int64) {
+	t.Helper()
+
+	pending, err := client.XPending(context.Background(), DefaultStream, node).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending.Count != want {
+		t.Fatalf("got %d pending entries, want %d", pending.Count, want)
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	client := newClient(t)
+	b := newBroker(client, "node-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages := subscribe(t, ctx, b, []string{"user/alice"}, []string{"topic/chat.*"})
+	push(t, b, "user/bob", "skipped")
+	push(t, b, "user/alice", "direct")
+	push(t, b, "topic/chat", "skipped")
+	push(t, b, "topic/chat.room", "pattern")
+
+	receive(t, messages,
+		broker.Message[string]{Channel: "user/alice", Data: "direct"},
+		broker.Message[string]{Channel: "topic/chat.room", Data: "pattern"},
+	)
+	// skipped entries are acked as well
+	assertPending(t, client, "node-1", 0)
+}
+
+func TestResumesFromGroupPosition(t *testing.T) {
+	client := newClient(t)
+	b := newBroker(client, "node-1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := subscribe(t, ctx, b, []string{"topic/orders"}, nil)
+	push(t, b, "topic/orders", "before")
+	receive(t, messages, broker.Message[string]{Channel: "topic/orders", Data: "before"})
+	stop(cancel, messages)
+
+	push(t, b, "topic/orders", "while down")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	messages = subscribe(t, ctx, newBroker(client, "node-1"), []string{"topic/orders"}, nil)
+	receive(t, messages, broker.Message[string]{Channel: "topic/orders", Data: "while down"})
+}
+
+func TestDeliversPendingEntriesFirst(t *testing.T) {
+	client := newClient(t)
+	b := newBroker(client, "node-1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := b.PubSub().(*PubSub[string]).createGroup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	push(t, b, "topic/orders", "read before a crash")
+	// read without acking, like a node that crashed before handing the entry over
+	_, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "node-1",
+		Consumer: "node-1",
+		Streams:  []string{DefaultStream, ">"},
+		Block:    -1,
+	}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertPending(t, client, "node-1", 1)
+
+	push(t, b, "topic/orders", "new")
+	messages := subscribe(t, ctx, b, []string{"topic/orders"}, nil)
+	receive(t, messages,
+		broker.Message[string]{Channel: "topic/orders", Data: "read before a crash"},
+		broker.Message[string]{Channel: "topic/orders", Data: "new"},
+	)
+	assertPending(t, client, "node-1", 0)
+}
+
+func TestNodesReadTheWholeStream(t *testing.T) {
+	client := newClient(t)
+	first, second := newBroker(client, "node-1"), newBroker(client, "node-2")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	firstMessages := subscribe(t, ctx, first, []string{"topic/chat"}, nil)
+	secondMessages := subscribe(t, ctx, second, []string{"topic/chat"}, nil)
+	push(t, first, "topic/chat", "1")
+	push(t, second, "topic/chat", "2")
+
+	want := []broker.Message[string]{
+		{Channel: "topic/chat", Data: "1"},
+		{Channel: "topic/chat", Data: "2"},
+	}
+	receive(t, firstMessages, want...)
+	receive(t, secondMessages, want...)
+}
+
+func TestSingleConsumingPubSub(t *testing.T) {
+	b := newBroker(newClient(t), "node-1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := subscribe(t, ctx, b, []string{"topic/chat"}, nil)
+	if _, err := b.PubSub().Channel(context.Background()); err != ErrConsuming {
+		t.Fatalf("got %v, want %v", err, ErrConsuming)
+	}
+	stop(cancel, messages)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	messages = subscribe(t, ctx, b, []string{"topic/chat"}, nil)
+	push(t, b, "topic/chat", "again")
+	receive(t, messages, broker.Message[string]{Channel: "topic/chat", Data: "again"})
+}
+
+func TestNegativeBufferSizeKeepsDefault(t *testing.T) {
+	b := New[string](nil, stringCodec, "node-1", WithBufferSize(-1))
+	if b.config.bufferSize != DefaultBufferSize {
+		t.Fatalf("got buffer size %d, want %d", b.config.bufferSize, DefaultBufferSize)
+	}
+}
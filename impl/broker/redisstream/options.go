@@ -0,0 +1,70 @@
+package redisstream
+
+import "time"
+
+const (
+	DefaultStream     = "sakura:stream"
+	DefaultMaxLen     = 100000
+	DefaultBatchSize  = 128
+	DefaultBlock      = 5 * time.Second
+	DefaultBufferSize = 512
+)
+
+type config struct {
+	stream     string
+	maxLen     int64
+	batchSize  int64
+	block      time.Duration
+	bufferSize int
+}
+
+func defaultConfig() config {
+	return config{
+		stream:     DefaultStream,
+		maxLen:     DefaultMaxLen,
+		batchSize:  DefaultBatchSize,
+		block:      DefaultBlock,
+		bufferSize: DefaultBufferSize,
+	}
+}
+
+type Option func(*config)
+
+// WithStream sets the key of the stream all channels are multiplexed into.
+func WithStream(key string) Option {
+	return func(c *config) {
+		c.stream = key
+	}
+}
+
+// WithMaxLen caps the stream length (approximately), a non-positive value disables trimming.
+func WithMaxLen(maxLen int64) Option {
+	return func(c *config) {
+		c.maxLen = maxLen
+	}
+}
+
+// WithBatchSize sets how many entries are read at once.
+func WithBatchSize(size int64) Option {
+	return func(c *config) {
+		c.batchSize = size
+	}
+}
+
+// WithBlock sets how long a read waits for new entries,
+// it also bounds how long stopping a subscription may take.
+func WithBlock(block time.Duration) Option {
+	return func(c *config) {
+		c.block = block
+	}
+}
+
+// WithBufferSize sets how many decoded entries may wait in a channel returned by PubSub.Channel,
+// entries are acked only once they're taken into it. Negative sizes keep the default.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		if size >= 0 {
+			c.bufferSize = size
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package aesgcm
+
+import (
+	"crypto/rand"
+	"errors"
+	"sakura/common/data/codec"
+)
+
+var ErrMalformed = errors.New("malformed payload")
+
+// New returns a codec producing len(key id) | key id | nonce | ciphertext.
+// The key id is authenticated as additional data.
+func New(keyring *Keyring) codec.Codec[[]byte, []byte] {
+	return codec.New(
+		func(payload []byte) ([]byte, error) {
+			id, aead, err := keyring.getPrimary()
+			if err != nil {
+				return nil, err
+			}
+
+			header := append([]byte{byte(len(id))}, id...)
+			nonce := make([]byte, aead.NonceSize())
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, err
+			}
+
+			result := make([]byte, 0, len(header)+len(nonce)+len(payload)+aead.Overhead())
+			result = append(result, header...)
+			result = append(result, nonce...)
+			return aead.Seal(result, nonce, payload, []byte(id)), nil
+		},
+		func(payload []byte) ([]byte, error) {
+			if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+				return nil, ErrMalformed
+			}
+			id := string(payload[1 : 1+payload[0]])
+			payload = payload[1+len(id):]
+
+			aead, err := keyring.get(id)
+			if err != nil {
+				return nil, err
+			}
+			if len(payload) < aead.NonceSize() {
+				return nil, ErrMalformed
+			}
+			nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+			return aead.Open(nil, nonce, ciphertext, []byte(id))
+		},
+	)
+}
+
+func Wrap[T any](inner codec.Binary[T], keyring *Keyring) codec.Binary[T] {
+	return codec.Compose[T, []byte, []byte](inner, New(keyring))
+}
@@ -0,0 +1,161 @@
+package aesgcm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, 16)
+	newKey = bytes.Repeat([]byte{2}, 32)
+)
+
+func newKeyring(t *testing.T) *Keyring {
+	keyring := NewKeyring()
+	if err := keyring.Add("old", oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := keyring.Add("new", newKey); err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func encode(t *testing.T, keyring *Keyring, payload []byte) []byte {
+	t.Helper()
+
+	encrypted, err := New(keyring).Encoder().Convert(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted
+}
+
+func TestRoundTrip(t *testing.T) {
+	keyring := newKeyring(t)
+	payload := []byte("sakura")
+
+	encrypted := encode(t, keyring, payload)
+	if !bytes.HasPrefix(encrypted, []byte("\x03old")) {
+		t.Fatalf("got header %q, want the primary key id", encrypted[:4])
+	}
+	if bytes.Contains(encrypted, payload) {
+		t.Fatal("the payload is readable")
+	}
+
+	decrypted, err := New(keyring).Decoder().Convert(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, payload) {
+		t.Fatalf("got %q, want %q", decrypted, payload)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	keyring := newKeyring(t)
+	encrypted := encode(t, keyring, []byte("sakura"))
+
+	tests := []struct {
+		name   string
+		tamper func(payload []byte) []byte
+	}{
+		{"ciphertext", func(payload []byte) []byte {
+			payload[len(payload)-1] ^= 1
+			return payload
+		}},
+		{"nonce", func(payload []byte) []byte {
+			payload[4] ^= 1
+			return payload
+		}},
+		// the ciphertext is authenticated with the id of the key it was encrypted with
+		{"key id", func(payload []byte) []byte {
+			return append([]byte("\x03new"), payload[4:]...)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tampered := test.tamper(append([]byte(nil), encrypted...))
+			if _, err := New(keyring).Decoder().Convert(tampered); err == nil {
+				t.Fatal("decrypted a tampered payload")
+			}
+		})
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	keyring := newKeyring(t)
+
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"empty", nil},
+		{"key id longer than the payload", []byte("\x05old")},
+		{"no nonce", []byte("\x03old\x00")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := New(keyring).Decoder().Convert(test.payload); !errors.Is(err, ErrMalformed) {
+				t.Fatalf("got %v, want %v", err, ErrMalformed)
+			}
+		})
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	encrypted := encode(t, newKeyring(t), []byte("sakura"))
+
+	keyring := NewKeyring()
+	if err := keyring.Add("other", oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(keyring).Decoder().Convert(encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	keyring := newKeyring(t)
+	payload := []byte("sakura")
+	before := encode(t, keyring, payload)
+
+	if err := keyring.SetPrimary("new"); err != nil {
+		t.Fatal(err)
+	}
+	after := encode(t, keyring, payload)
+	if !bytes.HasPrefix(after, []byte("\x03new")) {
+		t.Fatalf("got header %q, want the new primary key id", after[:4])
+	}
+
+	// payloads of the old key stay readable until it's removed
+	for _, encrypted := range [][]byte{before, after} {
+		decrypted, err := New(keyring).Decoder().Convert(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decrypted, payload) {
+			t.Fatalf("got %q, want %q", decrypted, payload)
+		}
+	}
+
+	keyring.Remove("old")
+	if _, err := New(keyring).Decoder().Convert(before); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownKey)
+	}
+	if _, err := New(keyring).Decoder().Convert(after); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring.Remove("new")
+	if _, err := New(keyring).Encoder().Convert(payload); !errors.Is(err, ErrNoPrimary) {
+		t.Fatalf("got %v, want %v", err, ErrNoPrimary)
+	}
+}
+
+func TestSetPrimaryUnknownKey(t *testing.T) {
+	if err := newKeyring(t).SetPrimary("missing"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownKey)
+	}
+}
@@ -0,0 +1,98 @@
+package aesgcm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+var (
+	ErrUnknownKey = errors.New("unknown key")
+	ErrNoPrimary  = errors.New("no primary key")
+)
+
+// Keyring holds every key that may still be referenced by in-flight payloads.
+// New payloads are always encrypted with the primary key.
+type Keyring struct {
+	keys    map[string]cipher.AEAD
+	primary string
+	mu      sync.RWMutex
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: map[string]cipher.AEAD{},
+		mu:   sync.RWMutex{},
+	}
+}
+
+// Add registers a 16, 24 or 32 byte key. The first added key becomes the primary one.
+func (keyring *Keyring) Add(id string, key []byte) error {
+	if len(id) == 0 || len(id) > math.MaxUint8 {
+		return fmt.Errorf("key id must be 1-%d bytes long", math.MaxUint8)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+
+	keyring.keys[id] = aead
+	if keyring.primary == "" {
+		keyring.primary = id
+	}
+	return nil
+}
+
+func (keyring *Keyring) SetPrimary(id string) error {
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+
+	if _, ok := keyring.keys[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	keyring.primary = id
+	return nil
+}
+
+// Remove forgets the key, payloads encrypted with it can't be decrypted anymore.
+func (keyring *Keyring) Remove(id string) {
+	keyring.mu.Lock()
+	defer keyring.mu.Unlock()
+
+	delete(keyring.keys, id)
+	if keyring.primary == id {
+		keyring.primary = ""
+	}
+}
+
+func (keyring *Keyring) getPrimary() (string, cipher.AEAD, error) {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	if keyring.primary == "" {
+		return "", nil, ErrNoPrimary
+	}
+	return keyring.primary, keyring.keys[keyring.primary], nil
+}
+
+func (keyring *Keyring) get(id string) (cipher.AEAD, error) {
+	keyring.mu.RLock()
+	defer keyring.mu.RUnlock()
+
+	aead, ok := keyring.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return aead, nil
+}
@@ -0,0 +1,143 @@
+package binary
+
+import (
+	"encoding/binary"
+	"errors"
+	"sakura/common/data/codec"
+	"sakura/core/event"
+	"sort"
+	"time"
+)
+
+// Version is the default encoding, LatestVersion (v2) is decoded but encoded only with codec.WithVersion,
+// so mixed deployments keep understanding each other until every node reads v2.
+const (
+	Version       byte = 1
+	LatestVersion byte = 2
+)
+
+var ErrMalformed = errors.New("malformed payload")
+
+// New returns a compact codec. Strings are prefixed with their uvarint length.
+//
+//	v1: name | data
+//	v2: name | id | varint(unix nanos, 0 if unset) | publisher | content type | uvarint(len(headers)) | (key | value)... | data
+func New(opts ...codec.VersionOption) codec.Binary[event.Event] {
+	return codec.Versioned(codec.EncodingVersion(Version, opts...), map[byte]codec.Binary[event.Event]{
+		1: codec.New(encodeV1, decodeV1),
+		2: codec.New(encodeV2, decodeV2),
+	})
+}
+
+func encodeV1(ev event.Event) ([]byte, error) {
+	payload := make([]byte, 0, binary.MaxVarintLen64+len(ev.Name)+len(ev.Data))
+	payload = appendString(payload, ev.Name)
+	payload = append(payload, ev.Data...)
+	return payload, nil
+}
+
+func decodeV1(payload []byte) (event.Event, error) {
+	name, rest, err := readString(payload)
+	if err != nil {
+		return event.Event{}, err
+	}
+	return event.Event{
+		Name: name,
+		Data: rest,
+	}, nil
+}
+
+func encodeV2(ev event.Event) ([]byte, error) {
+	var timestamp int64
+	if !ev.Timestamp.IsZero() {
+		timestamp = ev.Timestamp.UnixNano()
+	}
+
+	payload := make([]byte, 0, 64+len(ev.Name)+len(ev.ID)+len(ev.Data))
+	payload = appendString(payload, ev.Name)
+	payload = appendString(payload, ev.ID)
+	payload = binary.AppendVarint(payload, timestamp)
+	payload = appendString(payload, ev.Publisher)
+	payload = appendString(payload, ev.ContentType)
+
+	// sorted keys keep the encoding deterministic
+	keys := make([]string, 0, len(ev.Headers))
+	for key := range ev.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	payload = binary.AppendUvarint(payload, uint64(len(keys)))
+	for _, key := range keys {
+		payload = appendString(payload, key)
+		payload = appendString(payload, ev.Headers[key])
+	}
+
+	payload = append(payload, ev.Data...)
+	return payload, nil
+}
+
+func decodeV2(payload []byte) (event.Event, error) {
+	var (
+		ev  event.Event
+		err error
+	)
+
+	if ev.Name, payload, err = readString(payload); err != nil {
+		return event.Event{}, err
+	}
+	if ev.ID, payload, err = readString(payload); err != nil {
+		return event.Event{}, err
+	}
+
+	timestamp, n := binary.Varint(payload)
+	if n <= 0 {
+		return event.Event{}, ErrMalformed
+	}
+	payload = payload[n:]
+	if timestamp != 0 {
+		ev.Timestamp = time.Unix(0, timestamp)
+	}
+
+	if ev.Publisher, payload, err = readString(payload); err != nil {
+		return event.Event{}, err
+	}
+	if ev.ContentType, payload, err = readString(payload); err != nil {
+		return event.Event{}, err
+	}
+
+	count, n := binary.Uvarint(payload)
+	if n <= 0 || count > uint64(len(payload)) {
+		return event.Event{}, ErrMalformed
+	}
+	payload = payload[n:]
+	if count > 0 {
+		ev.Headers = make(map[string]string, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		var key, value string
+		if key, payload, err = readString(payload); err != nil {
+			return event.Event{}, err
+		}
+		if value, payload, err = readString(payload); err != nil {
+			return event.Event{}, err
+		}
+		ev.Headers[key] = value
+	}
+
+	ev.Data = payload
+	return ev, nil
+}
+
+func appendString(payload []byte, value string) []byte {
+	payload = binary.AppendUvarint(payload, uint64(len(value)))
+	return append(payload, value...)
+}
+
+func readString(payload []byte) (string, []byte, error) {
+	length, n := binary.Uvarint(payload)
+	if n <= 0 || uint64(len(payload)-n) < length {
+		return "", nil, ErrMalformed
+	}
+	payload = payload[n:]
+	return string(payload[:length]), payload[length:], nil
+}
@@ -0,0 +1,43 @@
+package binary
+
+import (
+	"errors"
+	"sakura/common/data/codec"
+	"sakura/core/event"
+	"testing"
+)
+
+func TestMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{"no name length", []byte{Version}},
+		{"name longer than the payload", []byte{Version, 5, 'a', 'b'}},
+		{"overlong length", []byte{Version, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := New().Decoder().Convert(test.payload); !errors.Is(err, ErrMalformed) {
+				t.Fatalf("got %v, want %v", err, ErrMalformed)
+			}
+		})
+	}
+}
+
+func TestMalformedMetadata(t *testing.T) {
+	ev := event.New("message", []byte("data"), event.WithPublisher("node-1"), event.WithHeader("trace", "abc"))
+	payload, err := New(codec.WithVersion(LatestVersion)).Encoder().Convert(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := New().Decoder()
+	// the data runs to the end, so only cuts within the metadata are malformed
+	metadata := len(payload) - len(ev.Data)
+	for cut := 1; cut < metadata; cut++ {
+		if _, err := decoder.Convert(payload[:cut]); !errors.Is(err, ErrMalformed) {
+			t.Fatalf("cut at %d: got %v, want %v", cut, err, ErrMalformed)
+		}
+	}
+}
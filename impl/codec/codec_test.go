@@ -0,0 +1,176 @@
+package codec_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"sakura/common/data/codec"
+	"sakura/core/event"
+	"sakura/impl/codec/binary"
+	"sakura/impl/codec/json"
+	"sakura/impl/codec/msgpack"
+	"testing"
+	"time"
+)
+
+type newCodec func(opts ...codec.VersionOption) codec.Binary[event.Event]
+
+// codecs are the event codecs every test runs against.
+var codecs = []struct {
+	name    string
+	version byte
+	latest  byte
+	new     newCodec
+}{
+	{"json", json.Version, json.LatestVersion, json.New},
+	{"msgpack", msgpack.Version, msgpack.LatestVersion, msgpack.New},
+	{"binary", binary.Version, binary.LatestVersion, binary.New},
+}
+
+func eachCodec(t *testing.T, test func(t *testing.T, c codec.Binary[event.Event], version byte)) {
+	for _, c := range codecs {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			test(t, c.new(), c.version)
+		})
+	}
+}
+
+// eachLatest runs the test against every codec with the version its encoder defaults to and its latest version.
+func eachLatest(t *testing.T, test func(t *testing.T, new newCodec, version, latest byte)) {
+	for _, c := range codecs {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			test(t, c.new, c.version, c.latest)
+		})
+	}
+}
+
+var full = event.Event{
+	Name: "message",
+	Data: []byte(`{"text":"hi"}`),
+	Metadata: event.Metadata{
+		ID:          "0000000000000001ffffffffffffffff",
+		Timestamp:   time.Unix(1700000000, 123456789),
+		Publisher:   "node-1",
+		ContentType: "application/json",
+		Headers:     map[string]string{"trace": "abc", "tenant": "acme"},
+	},
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		event event.Event
+	}{
+		{"name and data", event.Event{Name: "message", Data: []byte(`{"text":"hi"}`)}},
+		{"without data", event.Event{Name: "ping"}},
+		{"without name", event.Event{Data: []byte("data")}},
+		{"binary data", event.Event{Name: "blob", Data: []byte{0, 1, 0xfe, 0xff}}},
+		{"unicode name", event.Event{Name: "сообщение", Data: []byte("x")}},
+	}
+
+	eachCodec(t, func(t *testing.T, c codec.Binary[event.Event], version byte) {
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				payload, err := c.Encoder().Convert(test.event)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if payload[0] != version {
+					t.Fatalf("got version byte %d, want %d", payload[0], version)
+				}
+
+				got, err := c.Decoder().Convert(payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Name != test.event.Name || !bytes.Equal(got.Data, test.event.Data) {
+					t.Fatalf("got %q %q, want %q %q", got.Name, got.Data, test.event.Name, test.event.Data)
+				}
+			})
+		}
+	})
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	eachCodec(t, func(t *testing.T, c codec.Binary[event.Event], version byte) {
+		payload, err := c.Encoder().Convert(event.Event{Name: "message"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload[0] = 0xff
+
+		if _, err := c.Decoder().Convert(payload); !errors.Is(err, codec.ErrUnsupportedVersion) {
+			t.Fatalf("got %v, want %v", err, codec.ErrUnsupportedVersion)
+		}
+	})
+}
+
+func TestEmptyPayload(t *testing.T) {
+	eachCodec(t, func(t *testing.T, c codec.Binary[event.Event], version byte) {
+		if _, err := c.Decoder().Convert(nil); err == nil {
+			t.Fatal("decoded an empty payload")
+		}
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	eachLatest(t, func(t *testing.T, new newCodec, version, latest byte) {
+		tests := []struct {
+			name    string
+			version byte
+			event   event.Event
+			want    event.Event
+		}{
+			{"default drops the metadata", version, full, event.Event{Name: full.Name, Data: full.Data}},
+			{"latest keeps the metadata", latest, full, full},
+			{"latest without metadata", latest, event.Event{Name: "ping"}, event.Event{Name: "ping"}},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				payload, err := new(codec.WithVersion(test.version)).Encoder().Convert(test.event)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if payload[0] != test.version {
+					t.Fatalf("got version byte %d, want %d", payload[0], test.version)
+				}
+
+				// a node that still encodes the default version decodes the latest one
+				got, err := new().Decoder().Convert(payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertEvent(t, got, test.want)
+			})
+		}
+	})
+}
+
+func TestUnsupportedEncodingVersion(t *testing.T) {
+	eachLatest(t, func(t *testing.T, new newCodec, version, latest byte) {
+		if _, err := new(codec.WithVersion(latest + 1)).Encoder().Convert(full); !errors.Is(err, codec.ErrUnsupportedVersion) {
+			t.Fatalf("got %v, want %v", err, codec.ErrUnsupportedVersion)
+		}
+	})
+}
+
+func assertEvent(t *testing.T, got, want event.Event) {
+	t.Helper()
+
+	if got.Name != want.Name || !bytes.Equal(got.Data, want.Data) {
+		t.Fatalf("got %q %q, want %q %q", got.Name, got.Data, want.Name, want.Data)
+	}
+	if got.ID != want.ID || got.Publisher != want.Publisher || got.ContentType != want.ContentType {
+		t.Fatalf("got metadata %+v, want %+v", got.Metadata, want.Metadata)
+	}
+	if !got.Timestamp.Equal(want.Timestamp) {
+		t.Fatalf("got timestamp %v, want %v", got.Timestamp, want.Timestamp)
+	}
+	if len(got.Headers) != 0 || len(want.Headers) != 0 {
+		if !reflect.DeepEqual(got.Headers, want.Headers) {
+			t.Fatalf("got headers %v, want %v", got.Headers, want.Headers)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package compress
+
+import (
+	"errors"
+	"fmt"
+	"sakura/common/data/codec"
+)
+
+type Algorithm byte
+
+const (
+	None Algorithm = iota
+	Gzip
+	Zstd
+	Snappy
+)
+
+// DefaultMaxSize is how large a payload may get when decompressed by default.
+const DefaultMaxSize = 16 << 20
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown compression algorithm")
+	ErrTooLarge         = errors.New("decompressed payload is too large")
+)
+
+type config struct {
+	maxSize int
+}
+
+type Option func(*config)
+
+// WithMaxSize sets how large a payload may get when decompressed, decoding a larger one
+// fails with ErrTooLarge before it's inflated in memory. Sizes below 1 keep the default.
+func WithMaxSize(size int) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.maxSize = size
+		}
+	}
+}
+
+type compressor interface {
+	compress([]byte) ([]byte, error)
+	decompress([]byte) ([]byte, error)
+}
+
+// New returns a codec that compresses payloads of at least threshold bytes with the algorithm.
+// Every payload is tagged with the algorithm it was compressed with, so payloads produced
+// with any algorithm can be decoded regardless of the configured one.
+func New(algorithm Algorithm, threshold int, opts ...Option) codec.Codec[[]byte, []byte] {
+	cfg := config{maxSize: DefaultMaxSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	compressors := map[Algorithm]compressor{
+		None:   noneCompressor{},
+		Gzip:   gzipCompressor{maxSize: cfg.maxSize},
+		Zstd:   newZstdCompressor(cfg.maxSize),
+		Snappy: snappyCompressor{maxSize: cfg.maxSize},
+	}
+
+	return codec.New(
+		func(payload []byte) ([]byte, error) {
+			used := algorithm
+			if len(payload) < threshold {
+				used = None
+			}
+			c, ok := compressors[used]
+			if !ok {
+				return nil, fmt.Errorf("%w: %d", ErrUnknownAlgorithm, used)
+			}
+			compressed, err := c.compress(payload)
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte{byte(used)}, compressed...), nil
+		},
+		func(payload []byte) ([]byte, error) {
+			if len(payload) == 0 {
+				return nil, errors.New("empty payload")
+			}
+			c, ok := compressors[Algorithm(payload[0])]
+			if !ok {
+				return nil, fmt.Errorf("%w: %d", ErrUnknownAlgorithm, payload[0])
+			}
+			return c.decompress(payload[1:])
+		},
+	)
+}
+
+func Wrap[T any](inner codec.Binary[T], algorithm Algorithm, threshold int, opts ...Option) codec.Binary[T] {
+	return codec.Compose[T, []byte, []byte](inner, New(algorithm, threshold, opts...))
+}
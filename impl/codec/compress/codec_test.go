@@ -0,0 +1,92 @@
+package compress
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var algorithms = map[string]Algorithm{"gzip": Gzip, "zstd": Zstd, "snappy": Snappy}
+
+func TestMaxSize(t *testing.T) {
+	payload := bytes.Repeat([]byte("sakura"), 1000)
+
+	for name, algorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			compressed, err := New(algorithm, 0).Encoder().Convert(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decompressed, err := New(algorithm, 0, WithMaxSize(len(payload))).Decoder().Convert(compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decompressed, payload) {
+				t.Fatal("payload changed in the round trip")
+			}
+
+			if _, err := New(algorithm, 0, WithMaxSize(len(payload)-1)).Decoder().Convert(compressed); !errors.Is(err, ErrTooLarge) {
+				t.Fatalf("got %v, want %v", err, ErrTooLarge)
+			}
+		})
+	}
+}
+
+func TestBelowThreshold(t *testing.T) {
+	payload := []byte("sakura")
+
+	for name, algorithm := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			c := New(algorithm, len(payload)+1)
+			encoded, err := c.Encoder().Convert(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if Algorithm(encoded[0]) != None || !bytes.Equal(encoded[1:], payload) {
+				t.Fatalf("got %q, want the payload tagged as uncompressed", encoded)
+			}
+
+			decoded, err := c.Decoder().Convert(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decoded, payload) {
+				t.Fatalf("got %q, want %q", decoded, payload)
+			}
+		})
+	}
+}
+
+// TestOtherAlgorithms decodes payloads written with every algorithm, as nodes do while the algorithm changes.
+func TestOtherAlgorithms(t *testing.T) {
+	payload := bytes.Repeat([]byte("sakura"), 1000)
+
+	for writerName, writer := range algorithms {
+		encoded, err := New(writer, 0).Encoder().Convert(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Algorithm(encoded[0]) != writer {
+			t.Fatalf("%s: got algorithm %d", writerName, encoded[0])
+		}
+
+		for readerName, reader := range algorithms {
+			t.Run(writerName+" to "+readerName, func(t *testing.T) {
+				decoded, err := New(reader, 0).Decoder().Convert(encoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(decoded, payload) {
+					t.Fatal("payload changed in the round trip")
+				}
+			})
+		}
+	}
+}
+
+func TestUnknownAlgorithm(t *testing.T) {
+	if _, err := New(Gzip, 0).Decoder().Convert([]byte{0xff, 1, 2}); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Fatalf("got %v, want %v", err, ErrUnknownAlgorithm)
+	}
+}
@@ -0,0 +1,101 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"io"
+)
+
+type noneCompressor struct{}
+
+func (noneCompressor) compress(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+func (noneCompressor) decompress(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+type gzipCompressor struct {
+	maxSize int
+}
+
+func (gzipCompressor) compress(payload []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (c gzipCompressor) decompress(payload []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	// one byte over the limit tells a payload of exactly maxSize bytes from a larger one
+	decompressed, err := io.ReadAll(io.LimitReader(reader, int64(c.maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decompressed) > c.maxSize {
+		return nil, ErrTooLarge
+	}
+	return decompressed, nil
+}
+
+// zstdEncoder is shared by all codecs, the constructor doesn't fail without options.
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+type zstdCompressor struct {
+	decoder *zstd.Decoder
+}
+
+func newZstdCompressor(maxSize int) zstdCompressor {
+	// the only failing option is a zero max memory, which maxSize never is
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+	return zstdCompressor{
+		decoder: decoder,
+	}
+}
+
+func (c zstdCompressor) compress(payload []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(payload, nil), nil
+}
+
+func (c zstdCompressor) decompress(payload []byte) ([]byte, error) {
+	decompressed, err := c.decoder.DecodeAll(payload, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, ErrTooLarge
+	}
+	return decompressed, err
+}
+
+type snappyCompressor struct {
+	maxSize int
+}
+
+func (snappyCompressor) compress(payload []byte) ([]byte, error) {
+	return snappy.Encode(nil, payload), nil
+}
+
+// decompress checks the length snappy stores up front, so a large payload is never allocated.
+func (c snappyCompressor) decompress(payload []byte) ([]byte, error) {
+	length, err := snappy.DecodedLen(payload)
+	if err != nil {
+		return nil, err
+	}
+	if length > c.maxSize {
+		return nil, ErrTooLarge
+	}
+	return snappy.Decode(nil, payload)
+}
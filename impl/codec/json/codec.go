@@ -0,0 +1,86 @@
+package json
+
+import (
+	"encoding/json"
+	"sakura/common/data/codec"
+	"sakura/core/event"
+	"time"
+)
+
+// Version is the format New encodes with by default, LatestVersion adds the event metadata.
+// Every supported version is decoded, so a rollout first deploys the decoder everywhere
+// and only then switches the encoders with codec.WithVersion(LatestVersion).
+const (
+	Version       byte = 1
+	LatestVersion byte = 2
+)
+
+type messageV1 struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+type messageV2 struct {
+	Name        string            `json:"name"`
+	Data        []byte            `json:"data"`
+	ID          string            `json:"id,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Publisher   string            `json:"publisher,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+func New(opts ...codec.VersionOption) codec.Binary[event.Event] {
+	return codec.Versioned(codec.EncodingVersion(Version, opts...), map[byte]codec.Binary[event.Event]{
+		1: codec.New(encodeV1, decodeV1),
+		2: codec.New(encodeV2, decodeV2),
+	})
+}
+
+func encodeV1(ev event.Event) ([]byte, error) {
+	return json.Marshal(messageV1{
+		Name: ev.Name,
+		Data: ev.Data,
+	})
+}
+
+func decodeV1(payload []byte) (event.Event, error) {
+	var msg messageV1
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return event.Event{}, err
+	}
+	return event.Event{
+		Name: msg.Name,
+		Data: msg.Data,
+	}, nil
+}
+
+func encodeV2(ev event.Event) ([]byte, error) {
+	return json.Marshal(messageV2{
+		Name:        ev.Name,
+		Data:        ev.Data,
+		ID:          ev.ID,
+		Timestamp:   ev.Timestamp,
+		Publisher:   ev.Publisher,
+		ContentType: ev.ContentType,
+		Headers:     ev.Headers,
+	})
+}
+
+func decodeV2(payload []byte) (event.Event, error) {
+	var msg messageV2
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return event.Event{}, err
+	}
+	return event.Event{
+		Name: msg.Name,
+		Data: msg.Data,
+		Metadata: event.Metadata{
+			ID:          msg.ID,
+			Timestamp:   msg.Timestamp,
+			Publisher:   msg.Publisher,
+			ContentType: msg.ContentType,
+			Headers:     msg.Headers,
+		},
+	}, nil
+}
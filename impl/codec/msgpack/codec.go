@@ -0,0 +1,85 @@
+package msgpack
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+	"sakura/common/data/codec"
+	"sakura/core/event"
+	"time"
+)
+
+// Version is encoded by default and LatestVersion, which carries the event metadata, only on request:
+// nodes that don't know a version yet can't decode it, so it's enabled once all of them do.
+const (
+	Version       byte = 1
+	LatestVersion byte = 2
+)
+
+type messageV1 struct {
+	Name string `msgpack:"n"`
+	Data []byte `msgpack:"d"`
+}
+
+type messageV2 struct {
+	Name        string            `msgpack:"n"`
+	Data        []byte            `msgpack:"d"`
+	ID          string            `msgpack:"i,omitempty"`
+	Timestamp   time.Time         `msgpack:"t"`
+	Publisher   string            `msgpack:"p,omitempty"`
+	ContentType string            `msgpack:"c,omitempty"`
+	Headers     map[string]string `msgpack:"h,omitempty"`
+}
+
+func New(opts ...codec.VersionOption) codec.Binary[event.Event] {
+	return codec.Versioned(codec.EncodingVersion(Version, opts...), map[byte]codec.Binary[event.Event]{
+		1: codec.New(encodeV1, decodeV1),
+		2: codec.New(encodeV2, decodeV2),
+	})
+}
+
+func encodeV1(ev event.Event) ([]byte, error) {
+	return msgpack.Marshal(messageV1{
+		Name: ev.Name,
+		Data: ev.Data,
+	})
+}
+
+func decodeV1(payload []byte) (event.Event, error) {
+	var msg messageV1
+	if err := msgpack.Unmarshal(payload, &msg); err != nil {
+		return event.Event{}, err
+	}
+	return event.Event{
+		Name: msg.Name,
+		Data: msg.Data,
+	}, nil
+}
+
+func encodeV2(ev event.Event) ([]byte, error) {
+	return msgpack.Marshal(messageV2{
+		Name:        ev.Name,
+		Data:        ev.Data,
+		ID:          ev.ID,
+		Timestamp:   ev.Timestamp,
+		Publisher:   ev.Publisher,
+		ContentType: ev.ContentType,
+		Headers:     ev.Headers,
+	})
+}
+
+func decodeV2(payload []byte) (event.Event, error) {
+	var msg messageV2
+	if err := msgpack.Unmarshal(payload, &msg); err != nil {
+		return event.Event{}, err
+	}
+	return event.Event{
+		Name: msg.Name,
+		Data: msg.Data,
+		Metadata: event.Metadata{
+			ID:          msg.ID,
+			Timestamp:   msg.Timestamp,
+			Publisher:   msg.Publisher,
+			ContentType: msg.ContentType,
+			Headers:     msg.Headers,
+		},
+	}, nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"sakura/core/deadletter"
+	"sync"
+)
+
+var _ deadletter.Store = (*Store)(nil)
+
+type Store struct {
+	letters  []deadletter.Letter
+	capacity int
+	mu       sync.RWMutex
+}
+
+// New creates a store keeping up to capacity letters, the oldest letters are discarded first.
+// A non-positive capacity means no limit.
+func New(capacity int) *Store {
+	return &Store{
+		capacity: capacity,
+		mu:       sync.RWMutex{},
+	}
+}
+
+func (store *Store) Put(ctx context.Context, letter deadletter.Letter) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.letters = append(store.letters, letter)
+	if store.capacity > 0 && len(store.letters) > store.capacity {
+		store.letters = append([]deadletter.Letter(nil), store.letters[len(store.letters)-store.capacity:]...)
+	}
+	return nil
+}
+
+func (store *Store) List(ctx context.Context, user string, limit int) ([]deadletter.Letter, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var letters []deadletter.Letter
+	for _, letter := range store.letters {
+		if limit > 0 && len(letters) >= limit {
+			break
+		}
+		if user == "" || letter.User == user {
+			letters = append(letters, letter)
+		}
+	}
+	return letters, nil
+}
+
+func (store *Store) Remove(ctx context.Context, user, messageID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	kept := store.letters[:0]
+	for _, letter := range store.letters {
+		if letter.User != user || letter.Event.ID != messageID {
+			kept = append(kept, letter)
+		}
+	}
+	for i := len(kept); i < len(store.letters); i++ {
+		store.letters[i] = deadletter.Letter{}
+	}
+	store.letters = kept
+	return nil
+}
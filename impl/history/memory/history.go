@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"sakura/core/event"
+	"sakura/core/history"
+	"sync"
+	"time"
+)
+
+var _ history.History = (*History)(nil)
+
+type History struct {
+	topics map[string][]event.Event
+	policy history.Policy
+	mu     sync.RWMutex
+}
+
+func New(policy history.Policy) *History {
+	return &History{
+		topics: map[string][]event.Event{},
+		policy: policy,
+		mu:     sync.RWMutex{},
+	}
+}
+
+func (h *History) Append(ctx context.Context, topic string, ev event.Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := append(h.topics[topic], ev)
+
+	limits := h.policy(topic)
+	if limits.MaxLen > 0 && len(events) > limits.MaxLen {
+		events = append([]event.Event(nil), events[len(events)-limits.MaxLen:]...)
+	}
+	events = trimAge(events, limits.MaxAge)
+
+	h.topics[topic] = events
+	return nil
+}
+
+func (h *History) Range(ctx context.Context, topic string, since string, limit int) ([]event.Event, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	events := trimAge(h.topics[topic], h.policy(topic).MaxAge)
+
+	var result []event.Event
+	for _, ev := range events {
+		if ev.ID <= since {
+			continue
+		}
+		result = append(result, ev)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func trimAge(events []event.Event, maxAge time.Duration) []event.Event {
+	if maxAge <= 0 {
+		return events
+	}
+	deadline := time.Now().Add(-maxAge)
+	for i, ev := range events {
+		if ev.Timestamp.After(deadline) {
+			return events[i:]
+		}
+	}
+	return nil
+}
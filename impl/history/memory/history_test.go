@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"sakura/core/history"
+	"sakura/core/history/historytest"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	historytest.Run(t, func(t *testing.T, policy history.Policy) history.History {
+		return New(policy)
+	})
+}
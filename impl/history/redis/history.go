@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sakura/common/data/codec"
+	"sakura/core/event"
+	"sakura/core/history"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultPrefix = "sakura:history:"
+
+	eventField = "e"
+	// the id and the timestamp are kept next to the payload, since the codec may drop the event metadata
+	idField        = "i"
+	timestampField = "t"
+	// scanBatch is the number of entries read per request while looking for the since id
+	scanBatch = 128
+)
+
+var _ history.History = (*History)(nil)
+
+// History keeps a Redis stream per topic.
+type History struct {
+	client redis.UniversalClient
+	codec  codec.Binary[event.Event]
+	policy history.Policy
+	prefix string
+}
+
+func New(client redis.UniversalClient, codec codec.Binary[event.Event], policy history.Policy, prefix string) *History {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &History{
+		client: client,
+		codec:  codec,
+		policy: policy,
+		prefix: prefix,
+	}
+}
+
+func (h *History) Append(ctx context.Context, topic string, ev event.Event) error {
+	payload, err := h.codec.Encoder().Convert(ev)
+	if err != nil {
+		return err
+	}
+
+	limits := h.policy(topic)
+	key := h.key(topic)
+
+	_, err = h.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: key,
+			MaxLen: int64(limits.MaxLen),
+			Approx: true,
+			Values: map[string]any{eventField: payload, idField: ev.ID, timestampField: timestamp(ev.Timestamp)},
+		})
+		if limits.MaxAge > 0 {
+			pipe.XTrimMinIDApprox(ctx, key, minID(limits.MaxAge), 0)
+		}
+		return nil
+	})
+	return err
+}
+
+func (h *History) Range(ctx context.Context, topic string, since string, limit int) ([]event.Event, error) {
+	key := h.key(topic)
+	maxAge := h.policy(topic).MaxAge
+
+	// Event ids are unrelated to stream ids, so the stream is read backwards
+	// until an event not newer than since shows up.
+	var (
+		newer []event.Event
+		end   = "+"
+	)
+loop:
+	for {
+		messages, err := h.client.XRevRangeN(ctx, key, end, "-", scanBatch).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range messages {
+			ev, err := h.decode(message)
+			if err != nil {
+				return nil, err
+			}
+			if ev.ID <= since || (maxAge > 0 && time.Since(ev.Timestamp) > maxAge) {
+				break loop
+			}
+			newer = append(newer, ev)
+		}
+
+		if len(messages) < scanBatch {
+			break
+		}
+		end = "(" + messages[len(messages)-1].ID
+	}
+
+	result := make([]event.Event, 0, len(newer))
+	for i := len(newer) - 1; i >= 0; i-- {
+		result = append(result, newer[i])
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (h *History) key(topic string) string {
+	return h.prefix + topic
+}
+
+func (h *History) decode(message redis.XMessage) (event.Event, error) {
+	raw, okEvent := message.Values[eventField].(string)
+	id, okID := message.Values[idField].(string)
+	rawTimestamp, okTimestamp := message.Values[timestampField].(string)
+	nanos, err := strconv.ParseInt(rawTimestamp, 10, 64)
+	if !okEvent || !okID || !okTimestamp || err != nil {
+		return event.Event{}, fmt.Errorf("malformed history entry %s", message.ID)
+	}
+
+	ev, err := h.codec.Decoder().Convert([]byte(raw))
+	if err != nil {
+		return event.Event{}, err
+	}
+	ev.ID = id
+	ev.Timestamp = time.Time{}
+	if nanos != 0 {
+		ev.Timestamp = time.Unix(0, nanos)
+	}
+	return ev, nil
+}
+
+// timestamp returns the unix nanos of the time, 0 if it's unset.
+func timestamp(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func minID(maxAge time.Duration) string {
+	return strconv.FormatInt(time.Now().Add(-maxAge).UnixMilli(), 10)
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"sakura/core/history"
+	"sakura/core/history/historytest"
+	"sakura/impl/codec/json"
+	"testing"
+	"time"
+)
+
+// newHistory uses the default json codec, which drops the event metadata.
+func newHistory(t *testing.T, policy history.Policy) (*History, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return New(client, json.New(), policy, ""), server
+}
+
+func TestHistory(t *testing.T) {
+	historytest.Run(t, func(t *testing.T, policy history.Policy) history.History {
+		h, _ := newHistory(t, policy)
+		return h
+	})
+}
+
+func TestRangeReadsInBatches(t *testing.T) {
+	h, _ := newHistory(t, history.Uniform(history.Limits{}))
+	events := historytest.Events(2*scanBatch + 3)
+	historytest.Append(t, h, "chat", events...)
+
+	historytest.AssertRange(t, h, "chat", events[1].ID, 0, events[2:]...)
+}
+
+func TestMaxAgeTrimsStream(t *testing.T) {
+	h, server := newHistory(t, history.Uniform(history.Limits{MaxAge: time.Minute}))
+	events := historytest.Events(3)
+
+	server.SetTime(time.Now().Add(-time.Hour))
+	historytest.Append(t, h, "chat", events[0])
+	server.SetTime(time.Now())
+	historytest.Append(t, h, "chat", events[1:]...)
+
+	length, err := h.client.XLen(context.Background(), h.key("chat")).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length != 2 {
+		t.Fatalf("got %d entries, want 2", length)
+	}
+	historytest.AssertRange(t, h, "chat", "", 0, events[1:]...)
+}
+
+func TestMalformedEntry(t *testing.T) {
+	h, server := newHistory(t, history.Uniform(history.Limits{}))
+	if _, err := server.XAdd(h.key("chat"), "*", []string{eventField, "{}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := h.Range(context.Background(), "chat", "", 0); err == nil {
+		t.Fatal("read an entry without an id")
+	}
+}
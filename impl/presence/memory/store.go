@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"sakura/core/presence"
+	"sync"
+	"time"
+)
+
+var _ presence.Store = (*Store)(nil)
+
+type Store struct {
+	// expiration time of every user's entry per node
+	users map[string]map[string]time.Time
+	mu    sync.RWMutex
+}
+
+func New() *Store {
+	return &Store{
+		users: map[string]map[string]time.Time{},
+		mu:    sync.RWMutex{},
+	}
+}
+
+func (store *Store) Touch(ctx context.Context, user, node string, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.users[user]; !ok {
+		store.users[user] = map[string]time.Time{}
+	}
+	store.users[user][node] = time.Now().Add(ttl)
+	return nil
+}
+
+func (store *Store) Remove(ctx context.Context, user, node string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.users[user], node)
+	if len(store.users[user]) == 0 {
+		delete(store.users, user)
+	}
+	return nil
+}
+
+func (store *Store) Online(ctx context.Context, users ...string) ([]string, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	now := time.Now()
+	var online []string
+	for _, user := range users {
+		for _, expiration := range store.users[user] {
+			if expiration.After(now) {
+				online = append(online, user)
+				break
+			}
+		}
+	}
+	return online, nil
+}
@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"sakura/core/presence"
+	"strconv"
+	"time"
+)
+
+const DefaultPrefix = "sakura:presence:"
+
+var _ presence.Store = (*Store)(nil)
+
+// Store keeps a sorted set per user: members are nodes, scores are expiration times in milliseconds.
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func New(client redis.UniversalClient, prefix string) *Store {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Store{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (store *Store) Touch(ctx context.Context, user, node string, ttl time.Duration) error {
+	now := time.Now()
+	key := store.key(user)
+
+	_, err := store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{
+			Score:  float64(now.Add(ttl).UnixMilli()),
+			Member: node,
+		})
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(now.UnixMilli(), 10))
+		// the key outlives its longest entry by at most one ttl
+		pipe.PExpire(ctx, key, 2*ttl)
+		return nil
+	})
+	return err
+}
+
+func (store *Store) Remove(ctx context.Context, user, node string) error {
+	return store.client.ZRem(ctx, store.key(user), node).Err()
+}
+
+func (store *Store) Online(ctx context.Context, users ...string) ([]string, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	now := "(" + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	counts := make([]*redis.IntCmd, len(users))
+	_, err := store.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, user := range users {
+			counts[i] = pipe.ZCount(ctx, store.key(user), now, "+inf")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var online []string
+	for i, user := range users {
+		if counts[i].Val() > 0 {
+			online = append(online, user)
+		}
+	}
+	return online, nil
+}
+
+func (store *Store) key(user string) string {
+	return store.prefix + user
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"sakura/common/data"
+	"sakura/core/subscription"
+	"sync"
+)
+
+var _ subscription.Storage = (*Storage)(nil)
+
+type Storage struct {
+	users  map[string]map[string]struct{}
+	topics map[string]map[string]struct{}
+	mu     sync.RWMutex
+}
+
+func New() *Storage {
+	return &Storage{
+		users:  map[string]map[string]struct{}{},
+		topics: map[string]map[string]struct{}{},
+		mu:     sync.RWMutex{},
+	}
+}
+
+func (storage *Storage) Insert(ctx context.Context, sub subscription.Subscription) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	insert(storage.users, sub.User, sub.Topic)
+	insert(storage.topics, sub.Topic, sub.User)
+	return nil
+}
+
+func (storage *Storage) Select(ctx context.Context, selector subscription.Selector) (data.Set[subscription.Subscription], error) {
+	return Set{
+		storage:  storage,
+		selector: selector,
+	}, nil
+}
+
+// collect must be called with at least a read lock held.
+func (storage *Storage) collect(selector subscription.Selector) []subscription.Subscription {
+	var subs []subscription.Subscription
+
+	switch {
+	case selector.User != nil && selector.Topic != nil:
+		if _, ok := storage.users[*selector.User][*selector.Topic]; ok {
+			subs = append(subs, subscription.Subscription{User: *selector.User, Topic: *selector.Topic})
+		}
+	case selector.User != nil:
+		for topic := range storage.users[*selector.User] {
+			subs = append(subs, subscription.Subscription{User: *selector.User, Topic: topic})
+		}
+	case selector.Topic != nil:
+		for user := range storage.topics[*selector.Topic] {
+			subs = append(subs, subscription.Subscription{User: user, Topic: *selector.Topic})
+		}
+	default:
+		for user, topics := range storage.users {
+			for topic := range topics {
+				subs = append(subs, subscription.Subscription{User: user, Topic: topic})
+			}
+		}
+	}
+
+	return subs
+}
+
+type Set struct {
+	storage  *Storage
+	selector subscription.Selector
+}
+
+func (set Set) Erase(ctx context.Context) error {
+	set.storage.mu.Lock()
+	defer set.storage.mu.Unlock()
+
+	for _, sub := range set.storage.collect(set.selector) {
+		remove(set.storage.users, sub.User, sub.Topic)
+		remove(set.storage.topics, sub.Topic, sub.User)
+	}
+	return nil
+}
+
+func (set Set) Iter(ctx context.Context, iter func(subscription.Subscription) bool) {
+	set.storage.mu.RLock()
+	subs := set.storage.collect(set.selector)
+	set.storage.mu.RUnlock()
+
+	for _, sub := range subs {
+		if !iter(sub) {
+			return
+		}
+	}
+}
+
+func insert(index map[string]map[string]struct{}, key, value string) {
+	if _, ok := index[key]; !ok {
+		index[key] = map[string]struct{}{}
+	}
+	index[key][value] = struct{}{}
+}
+
+func remove(index map[string]map[string]struct{}, key, value string) {
+	values, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(values, value)
+	if len(values) == 0 {
+		delete(index, key)
+	}
+}
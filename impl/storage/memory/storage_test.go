@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"sakura/core/subscription"
+	"sakura/core/subscription/subscriptiontest"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	subscriptiontest.Run(t, func(t *testing.T) subscription.Storage {
+		return New()
+	})
+}
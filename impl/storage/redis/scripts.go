@@ -0,0 +1,51 @@
+package redis
+
+import "github.com/redis/go-redis/v9"
+
+// The keys known upfront are passed as KEYS, so a cluster client routes the script to their slot.
+// Keys read from sets are derived from the prefix inside the scripts, so the prefix must
+// contain a hash tag (e.g. "{sakura}") for all of them to live in that slot.
+
+// KEYS: user key, topic key, users key; ARGV: user, topic
+var insertScript = redis.NewScript(`
+local user, topic = ARGV[1], ARGV[2]
+redis.call('SADD', KEYS[1], topic)
+redis.call('SADD', KEYS[2], user)
+redis.call('SADD', KEYS[3], user)
+return 1
+`)
+
+// KEYS: users key; ARGV: prefix, user, topic, by user, by topic
+var eraseScript = redis.NewScript(`
+local users = KEYS[1]
+local prefix, user, topic = ARGV[1], ARGV[2], ARGV[3]
+local byUser, byTopic = ARGV[4] == '1', ARGV[5] == '1'
+local function unlink(u, t)
+	redis.call('SREM', prefix .. 'user:' .. u, t)
+	redis.call('SREM', prefix .. 'topic:' .. t, u)
+	if redis.call('SCARD', prefix .. 'user:' .. u) == 0 then
+		redis.call('SREM', users, u)
+	end
+end
+
+if byUser and byTopic then
+	unlink(user, topic)
+elseif byUser then
+	for _, t in ipairs(redis.call('SMEMBERS', prefix .. 'user:' .. user)) do
+		unlink(user, t)
+	end
+elseif byTopic then
+	for _, u in ipairs(redis.call('SMEMBERS', prefix .. 'topic:' .. topic)) do
+		unlink(u, topic)
+	end
+else
+	for _, u in ipairs(redis.call('SMEMBERS', users)) do
+		for _, t in ipairs(redis.call('SMEMBERS', prefix .. 'user:' .. u)) do
+			redis.call('DEL', prefix .. 'topic:' .. t)
+		end
+		redis.call('DEL', prefix .. 'user:' .. u)
+	end
+	redis.call('DEL', users)
+end
+return 1
+`)
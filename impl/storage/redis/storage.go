@@ -0,0 +1,132 @@
+package redis
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"log"
+	"sakura/common/data"
+	"sakura/core/subscription"
+)
+
+const DefaultPrefix = "{sakura}:subscriptions:"
+
+var _ subscription.Storage = (*Storage)(nil)
+
+type Storage struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func New(client redis.UniversalClient, prefix string) *Storage {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Storage{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (storage *Storage) Insert(ctx context.Context, sub subscription.Subscription) error {
+	keys := []string{storage.userKey(sub.User), storage.topicKey(sub.Topic), storage.usersKey()}
+	return insertScript.Run(ctx, storage.client, keys, sub.User, sub.Topic).Err()
+}
+
+func (storage *Storage) Select(ctx context.Context, selector subscription.Selector) (data.Set[subscription.Subscription], error) {
+	return Set{
+		storage:  storage,
+		selector: selector,
+	}, nil
+}
+
+func (storage *Storage) userKey(user string) string {
+	return storage.prefix + "user:" + user
+}
+
+func (storage *Storage) topicKey(topic string) string {
+	return storage.prefix + "topic:" + topic
+}
+
+func (storage *Storage) usersKey() string {
+	return storage.prefix + "users"
+}
+
+func (storage *Storage) collect(ctx context.Context, selector subscription.Selector) ([]subscription.Subscription, error) {
+	var subs []subscription.Subscription
+
+	switch {
+	case selector.User != nil && selector.Topic != nil:
+		ok, err := storage.client.SIsMember(ctx, storage.userKey(*selector.User), *selector.Topic).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			subs = append(subs, subscription.Subscription{User: *selector.User, Topic: *selector.Topic})
+		}
+	case selector.User != nil:
+		topics, err := storage.client.SMembers(ctx, storage.userKey(*selector.User)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, topic := range topics {
+			subs = append(subs, subscription.Subscription{User: *selector.User, Topic: topic})
+		}
+	case selector.Topic != nil:
+		users, err := storage.client.SMembers(ctx, storage.topicKey(*selector.Topic)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			subs = append(subs, subscription.Subscription{User: user, Topic: *selector.Topic})
+		}
+	default:
+		users, err := storage.client.SMembers(ctx, storage.usersKey()).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			topics, err := storage.client.SMembers(ctx, storage.userKey(user)).Result()
+			if err != nil {
+				return nil, err
+			}
+			for _, topic := range topics {
+				subs = append(subs, subscription.Subscription{User: user, Topic: topic})
+			}
+		}
+	}
+
+	return subs, nil
+}
+
+type Set struct {
+	storage  *Storage
+	selector subscription.Selector
+}
+
+func (set Set) Erase(ctx context.Context) error {
+	var user, topic string
+	byUser, byTopic := "0", "0"
+	if set.selector.User != nil {
+		user, byUser = *set.selector.User, "1"
+	}
+	if set.selector.Topic != nil {
+		topic, byTopic = *set.selector.Topic, "1"
+	}
+	return eraseScript.
+		Run(ctx, set.storage.client, []string{set.storage.usersKey()}, set.storage.prefix, user, topic, byUser, byTopic).
+		Err()
+}
+
+func (set Set) Iter(ctx context.Context, iter func(subscription.Subscription) bool) {
+	subs, err := set.storage.collect(ctx, set.selector)
+	if err != nil {
+		log.Println("failed to load subscriptions:", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if !iter(sub) {
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"sakura/common/util"
+	"sakura/core/subscription"
+	"sakura/core/subscription/subscriptiontest"
+	"strings"
+	"testing"
+)
+
+func newStorage(t *testing.T) (*Storage, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return New(client, ""), server
+}
+
+func TestStorage(t *testing.T) {
+	subscriptiontest.Run(t, func(t *testing.T) subscription.Storage {
+		storage, _ := newStorage(t)
+		return storage
+	})
+}
+
+func TestEraseDropsEmptyKeys(t *testing.T) {
+	storage, server := newStorage(t)
+	subscriptiontest.Insert(t, storage, subscriptiontest.AliceNews, subscriptiontest.BobNews)
+
+	subscriptiontest.Erase(t, storage, subscription.Selector{Topic: util.MakePtr("news")})
+	if keys := server.Keys(); len(keys) != 0 {
+		t.Fatalf("keys left behind: %v", keys)
+	}
+}
+
+func TestKeysShareHashTag(t *testing.T) {
+	storage, server := newStorage(t)
+	subscriptiontest.Insert(t, storage, subscriptiontest.AliceNews)
+
+	for _, key := range server.Keys() {
+		if !strings.HasPrefix(key, "{sakura}") {
+			t.Fatalf("key %q is outside the hash tag", key)
+		}
+	}
+}
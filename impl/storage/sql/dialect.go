@@ -0,0 +1,34 @@
+package sql
+
+import "fmt"
+
+type Dialect struct {
+	Name string
+
+	placeholder  func(n int) string
+	quote        func(identifier string) string
+	insertIgnore string
+}
+
+var (
+	SQLite = Dialect{
+		Name:         "sqlite",
+		placeholder:  func(int) string { return "?" },
+		quote:        func(identifier string) string { return `"` + identifier + `"` },
+		insertIgnore: `INSERT INTO subscriptions ("user", topic) VALUES (?, ?) ON CONFLICT DO NOTHING`,
+	}
+
+	Postgres = Dialect{
+		Name:         "postgres",
+		placeholder:  func(n int) string { return fmt.Sprintf("$%d", n) },
+		quote:        func(identifier string) string { return `"` + identifier + `"` },
+		insertIgnore: `INSERT INTO subscriptions ("user", topic) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+	}
+
+	MySQL = Dialect{
+		Name:         "mysql",
+		placeholder:  func(int) string { return "?" },
+		quote:        func(identifier string) string { return "`" + identifier + "`" },
+		insertIgnore: "INSERT IGNORE INTO subscriptions (`user`, topic) VALUES (?, ?)",
+	}
+)
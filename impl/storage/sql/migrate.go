@@ -0,0 +1,128 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrations embed.FS
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+func loadMigrations(dialect Dialect) ([]migration, error) {
+	dir := path.Join("migrations", dialect.Name)
+	entries, err := migrations.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported dialect %q: %w", dialect.Name, err)
+	}
+
+	var result []migration
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("malformed migration name %q: %w", name, err)
+		}
+		query, err := migrations.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, migration{
+			version: version,
+			name:    name,
+			query:   string(query),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].version < result[j].version })
+	return result, nil
+}
+
+func migrate(ctx context.Context, db *sql.DB, dialect Dialect) error {
+	list, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS sakura_migrations (version INTEGER NOT NULL PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	applied := map[int]struct{}{}
+	rows, err := db.QueryContext(ctx, `SELECT version FROM sakura_migrations`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = struct{}{}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		if err := apply(ctx, db, dialect, m); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, dialect Dialect, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Statements are executed one by one since not every driver accepts
+	// several statements in a single Exec call.
+	for _, statement := range statements(m.query) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	query := fmt.Sprintf(`INSERT INTO sakura_migrations (version) VALUES (%s)`, dialect.placeholder(1))
+	if _, err := tx.ExecContext(ctx, query, m.version); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// statements splits the query at semicolons, migrations never contain them in literals.
+func statements(query string) []string {
+	var result []string
+	for _, statement := range strings.Split(query, ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			result = append(result, statement)
+		}
+	}
+	return result
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+    `user`     VARCHAR(255) NOT NULL,
+    topic      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT subscriptions_user_topic_key UNIQUE (`user`, topic),
+    INDEX subscriptions_topic_user_idx (topic, `user`)
+);
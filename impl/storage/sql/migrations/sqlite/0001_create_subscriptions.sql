@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS subscriptions (
+    "user"     TEXT      NOT NULL,
+    topic      TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT subscriptions_user_topic_key UNIQUE ("user", topic)
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_topic_user_idx ON subscriptions (topic, "user");
@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sakura/common/data"
+	"sakura/core/subscription"
+	"strings"
+)
+
+var _ subscription.Storage = (*Storage)(nil)
+
+type Storage struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// New does not register any database driver, it's up to the caller
+// to import the one matching the dialect.
+func New(db *sql.DB, dialect Dialect) *Storage {
+	return &Storage{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+func (storage *Storage) Migrate(ctx context.Context) error {
+	return migrate(ctx, storage.db, storage.dialect)
+}
+
+func (storage *Storage) Insert(ctx context.Context, sub subscription.Subscription) error {
+	_, err := storage.db.ExecContext(ctx, storage.dialect.insertIgnore, sub.User, sub.Topic)
+	return err
+}
+
+func (storage *Storage) Select(ctx context.Context, selector subscription.Selector) (data.Set[subscription.Subscription], error) {
+	return Set{
+		storage:  storage,
+		selector: selector,
+	}, nil
+}
+
+func (storage *Storage) where(selector subscription.Selector) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	if selector.User != nil {
+		args = append(args, *selector.User)
+		conditions = append(conditions, fmt.Sprintf("%s = %s", storage.dialect.quote("user"), storage.dialect.placeholder(len(args))))
+	}
+	if selector.Topic != nil {
+		args = append(args, *selector.Topic)
+		conditions = append(conditions, fmt.Sprintf("topic = %s", storage.dialect.placeholder(len(args))))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+type Set struct {
+	storage  *Storage
+	selector subscription.Selector
+}
+
+func (set Set) Erase(ctx context.Context) error {
+	where, args := set.storage.where(set.selector)
+	_, err := set.storage.db.ExecContext(ctx, "DELETE FROM subscriptions"+where, args...)
+	return err
+}
+
+func (set Set) Iter(ctx context.Context, iter func(subscription.Subscription) bool) {
+	where, args := set.storage.where(set.selector)
+	query := fmt.Sprintf("SELECT %s, topic FROM subscriptions%s", set.storage.dialect.quote("user"), where)
+
+	rows, err := set.storage.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Println("failed to load subscriptions:", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sub subscription.Subscription
+		if err := rows.Scan(&sub.User, &sub.Topic); err != nil {
+			log.Println("failed to scan a subscription:", err)
+			return
+		}
+		if !iter(sub) {
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Println("failed to load subscriptions:", err)
+	}
+}
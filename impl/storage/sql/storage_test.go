@@ -0,0 +1,170 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+	"reflect"
+	"sakura/common/util"
+	"sakura/core/subscription"
+	"sakura/core/subscription/subscriptiontest"
+	"sakura/internal/testenv"
+	"strings"
+	"testing"
+)
+
+// dialects are tested against SQLite in memory and, given their data source names, Postgres and MySQL.
+var dialects = []struct {
+	dialect Dialect
+	open    func(t *testing.T) *sql.DB
+}{
+	{SQLite, openSQLite},
+	{Postgres, func(t *testing.T) *sql.DB { return openServer(t, "postgres", testenv.PostgresDSN) }},
+	{MySQL, func(t *testing.T) *sql.DB { return openServer(t, "mysql", testenv.MySQLDSN) }},
+}
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection opens its own in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// openServer connects to the database in the variable and drops the tables of earlier runs.
+func openServer(t *testing.T, driver, variable string) *sql.DB {
+	db, err := sql.Open(driver, testenv.DSN(t, variable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	for _, query := range []string{`DROP TABLE IF EXISTS subscriptions`, `DROP TABLE IF EXISTS sakura_migrations`} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// eachDialect runs the test against a migrated storage of every dialect.
+func eachDialect(t *testing.T, test func(t *testing.T, open func(t *testing.T) *Storage)) {
+	for _, d := range dialects {
+		d := d
+		t.Run(d.dialect.Name, func(t *testing.T) {
+			test(t, func(t *testing.T) *Storage {
+				storage := New(d.open(t), d.dialect)
+				if err := storage.Migrate(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+				return storage
+			})
+		})
+	}
+}
+
+func TestStorage(t *testing.T) {
+	eachDialect(t, func(t *testing.T, open func(t *testing.T) *Storage) {
+		subscriptiontest.Run(t, func(t *testing.T) subscription.Storage {
+			return open(t)
+		})
+	})
+}
+
+func TestMigrateTwice(t *testing.T) {
+	eachDialect(t, func(t *testing.T, open func(t *testing.T) *Storage) {
+		if err := open(t).Migrate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestWherePlaceholders(t *testing.T) {
+	selector := subscription.Selector{User: util.MakePtr("alice"), Topic: util.MakePtr("news")}
+
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{SQLite, ` WHERE "user" = ? AND topic = ?`},
+		{Postgres, ` WHERE "user" = $1 AND topic = $2`},
+		{MySQL, " WHERE `user` = ? AND topic = ?"},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect.Name, func(t *testing.T) {
+			where, args := New(nil, test.dialect).where(selector)
+			if where != test.want {
+				t.Fatalf("got %q, want %q", where, test.want)
+			}
+			if !reflect.DeepEqual(args, []any{"alice", "news"}) {
+				t.Fatalf("got args %v", args)
+			}
+		})
+	}
+}
+
+// TestMigrationStatements checks what apply executes, so dialects without a test database are covered too.
+func TestMigrationStatements(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		// the beginning of every statement of the migrations in order
+		want []string
+		// quotes of other dialects that must not appear
+		foreign string
+	}{
+		{SQLite, []string{"CREATE TABLE IF NOT EXISTS subscriptions (", "CREATE INDEX IF NOT EXISTS subscriptions_topic_user_idx ON subscriptions (topic, \"user\")"}, "`"},
+		{Postgres, []string{"CREATE TABLE IF NOT EXISTS subscriptions (", "CREATE INDEX IF NOT EXISTS subscriptions_topic_user_idx ON subscriptions (topic, \"user\")"}, "`"},
+		{MySQL, []string{"CREATE TABLE IF NOT EXISTS subscriptions ("}, `"`},
+	}
+	for _, test := range tests {
+		t.Run(test.dialect.Name, func(t *testing.T) {
+			list, err := loadMigrations(test.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, m := range list {
+				got = append(got, statements(m.query)...)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d statements %q, want %d", len(got), got, len(test.want))
+			}
+			for i, statement := range got {
+				if !strings.HasPrefix(statement, test.want[i]) {
+					t.Fatalf("statement %d: got %q, want it to start with %q", i, statement, test.want[i])
+				}
+				if strings.Contains(statement, test.foreign) {
+					t.Fatalf("statement %d quotes with %s: %q", i, test.foreign, statement)
+				}
+				if !strings.Contains(statement, test.dialect.quote("user")) {
+					t.Fatalf("statement %d doesn't quote the user column: %q", i, statement)
+				}
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"empty", " \n", nil},
+		{"single without a semicolon", "CREATE TABLE a (b INT)", []string{"CREATE TABLE a (b INT)"}},
+		{"several", "CREATE TABLE a (b INT);\n\nCREATE INDEX c ON a (b);\n", []string{"CREATE TABLE a (b INT)", "CREATE INDEX c ON a (b)"}},
+		{"empty statements", ";;CREATE TABLE a (b INT);  ;", []string{"CREATE TABLE a (b INT)"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := statements(test.query); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package longpoll
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sakura/impl/broadcaster"
+	"sakura/impl/transport"
+	"sync"
+	"time"
+)
+
+// LastSeenParam is the query parameter carrying the id of the last message
+// a reconnecting client has seen, messages published after it are replayed.
+const LastSeenParam = "last_seen"
+
+// AckParam may be repeated in a poll request to ack the delivery of reliable messages
+// received in the previous polls, see broadcaster.Envelope.
+const AckParam = "ack"
+
+type ConnectResponse struct {
+	Session string `json:"session"`
+}
+
+type PollResponse struct {
+	Messages []broadcaster.Envelope `json:"messages"`
+}
+
+// Handler serves the whole session lifecycle on a single endpoint:
+// POST opens a session, GET ?session=<token>[&ack=<id>...] polls it and DELETE ?session=<token> closes it.
+type Handler struct {
+	broadcaster  *broadcaster.Broadcaster
+	authenticate transport.Authenticator
+	config       config
+	sessions     map[string]*session
+	mu           sync.RWMutex
+}
+
+func New(broadcaster *broadcaster.Broadcaster, authenticate transport.Authenticator, opts ...Option) *Handler {
+	cfg := config{
+		pollTimeout: DefaultPollTimeout,
+		idleTimeout: DefaultIdleTimeout,
+		bufferSize:  DefaultBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Handler{
+		broadcaster:  broadcaster,
+		authenticate: authenticate,
+		config:       cfg,
+		sessions:     map[string]*session{},
+		mu:           sync.RWMutex{},
+	}
+}
+
+// Run disconnects idle sessions until the context is done.
+func (handler *Handler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(handler.config.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			handler.expire(ctx, now)
+		}
+	}
+}
+
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		handler.connect(w, r)
+	case http.MethodGet:
+		handler.poll(w, r)
+	case http.MethodDelete:
+		handler.close(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (handler *Handler) connect(w http.ResponseWriter, r *http.Request) {
+	userID, err := handler.authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		log.Println("failed to generate a session token:", err)
+		http.Error(w, "failed to create a session", http.StatusInternalServerError)
+		return
+	}
+
+	s := newSession(token, userID, handler.config.bufferSize)
+	s.connectionID, err = handler.broadcaster.Connect(r.Context(), s, broadcaster.WithLastSeen(r.URL.Query().Get(LastSeenParam)))
+	if err != nil {
+		log.Println("failed to connect a user:", err)
+		http.Error(w, "failed to connect", http.StatusInternalServerError)
+		return
+	}
+
+	handler.mu.Lock()
+	handler.sessions[token] = s
+	handler.mu.Unlock()
+
+	writeJSON(w, ConnectResponse{Session: token})
+}
+
+func (handler *Handler) poll(w http.ResponseWriter, r *http.Request) {
+	s, ok := handler.session(r)
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	if acks := r.URL.Query()[AckParam]; len(acks) > 0 {
+		handler.broadcaster.Ack(s.connectionID, acks...)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), handler.config.pollTimeout)
+	defer cancel()
+
+	envelopes, ok := s.poll(ctx)
+	if !ok {
+		handler.drop(r.Context(), s)
+		http.Error(w, "session is closed", http.StatusGone)
+		return
+	}
+
+	writeJSON(w, PollResponse{Messages: envelopes})
+}
+
+func (handler *Handler) close(w http.ResponseWriter, r *http.Request) {
+	s, ok := handler.session(r)
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	handler.drop(r.Context(), s)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *Handler) session(r *http.Request) (*session, bool) {
+	handler.mu.RLock()
+	defer handler.mu.RUnlock()
+
+	s, ok := handler.sessions[r.URL.Query().Get("session")]
+	return s, ok
+}
+
+func (handler *Handler) expire(ctx context.Context, now time.Time) {
+	var expired []*session
+
+	handler.mu.RLock()
+	for _, s := range handler.sessions {
+		if s.expired(now, handler.config.idleTimeout) {
+			expired = append(expired, s)
+		}
+	}
+	handler.mu.RUnlock()
+
+	for _, s := range expired {
+		handler.drop(ctx, s)
+	}
+}
+
+func (handler *Handler) drop(ctx context.Context, s *session) {
+	handler.mu.Lock()
+	_, ok := handler.sessions[s.token]
+	delete(handler.sessions, s.token)
+	handler.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	if err := handler.broadcaster.Disconnect(ctx, s.connectionID); err != nil {
+		log.Println("failed to disconnect a user:", err)
+	}
+}
+
+func newToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Println("failed to write a response:", err)
+	}
+}
@@ -0,0 +1,47 @@
+package longpoll
+
+import "time"
+
+const (
+	DefaultPollTimeout = 25 * time.Second
+	DefaultIdleTimeout = time.Minute
+	DefaultBufferSize  = 256
+)
+
+type config struct {
+	pollTimeout time.Duration
+	idleTimeout time.Duration
+	bufferSize  int
+}
+
+type Option func(*config)
+
+// WithPollTimeout sets how long a poll waits for messages before returning an empty batch,
+// non-positive timeouts keep the default.
+func WithPollTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		if timeout > 0 {
+			c.pollTimeout = timeout
+		}
+	}
+}
+
+// WithIdleTimeout sets how long a session survives without polls before it's disconnected,
+// sessions are checked twice per timeout. Timeouts under a millisecond keep the default.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		if timeout >= time.Millisecond {
+			c.idleTimeout = timeout
+		}
+	}
+}
+
+// WithBufferSize sets how many envelopes a session buffers between polls,
+// sizes below 1 keep the default.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.bufferSize = size
+		}
+	}
+}
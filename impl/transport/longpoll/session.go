@@ -0,0 +1,120 @@
+package longpoll
+
+import (
+	"context"
+	"errors"
+	"sakura/impl/broadcaster"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBufferFull = errors.New("session buffer is full")
+	ErrClosed     = errors.New("session is closed")
+)
+
+type session struct {
+	token        string
+	userID       string
+	connectionID string
+	limit        int
+	envelopes    []broadcaster.Envelope
+	notify       chan struct{}
+	polls        int
+	lastPoll     time.Time
+	closed       bool
+	mu           sync.Mutex
+}
+
+func newSession(token, userID string, limit int) *session {
+	return &session{
+		token:    token,
+		userID:   userID,
+		limit:    limit,
+		notify:   make(chan struct{}, 1),
+		lastPoll: time.Now(),
+	}
+}
+
+func (s *session) ID() string {
+	return s.userID
+}
+
+func (s *session) Send(ctx context.Context, payload []byte) error {
+	return s.SendEnvelope(ctx, broadcaster.Envelope{Data: payload})
+}
+
+func (s *session) SendEnvelope(ctx context.Context, envelope broadcaster.Envelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	if len(s.envelopes) >= s.limit {
+		return ErrBufferFull
+	}
+	s.envelopes = append(s.envelopes, envelope)
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close marks the session as closed, the handler drops it on the next poll or expiration check.
+func (s *session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// poll waits until there are buffered envelopes or the context is done and drains the buffer.
+// It reports false if the session is closed.
+func (s *session) poll(ctx context.Context) ([]broadcaster.Envelope, bool) {
+	s.mu.Lock()
+	s.polls++
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.polls--
+		s.lastPoll = time.Now()
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, false
+		}
+		if len(s.envelopes) > 0 {
+			envelopes := s.envelopes
+			s.envelopes = nil
+			s.mu.Unlock()
+			return envelopes, true
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, true
+		case <-s.notify:
+		}
+	}
+}
+
+func (s *session) expired(now time.Time, idleTimeout time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closed || (s.polls == 0 && now.Sub(s.lastPoll) > idleTimeout)
+}
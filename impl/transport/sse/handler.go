@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sakura/impl/broadcaster"
+	"sakura/impl/transport"
+	"time"
+)
+
+type Handler struct {
+	broadcaster  *broadcaster.Broadcaster
+	authenticate transport.Authenticator
+	config       config
+}
+
+func New(broadcaster *broadcaster.Broadcaster, authenticate transport.Authenticator, opts ...Option) *Handler {
+	cfg := config{
+		heartbeatInterval: DefaultHeartbeatInterval,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Handler{
+		broadcaster:  broadcaster,
+		authenticate: authenticate,
+		config:       cfg,
+	}
+}
+
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := handler.authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	s := &stream{
+		id:        userID,
+		envelopes: make(chan broadcaster.Envelope),
+		done:      ctx.Done(),
+		cancel:    cancel,
+	}
+
+	// event streams have no way back to ack reliable messages
+	connectionID, err := handler.broadcaster.Connect(ctx, s,
+		broadcaster.WithLastSeen(lastSeen(r.Header.Get("Last-Event-ID"))),
+		broadcaster.WithoutAcks(),
+	)
+	if err != nil {
+		log.Println("failed to connect a user:", err)
+		http.Error(w, "failed to connect", http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		if err := handler.broadcaster.Disconnect(context.Background(), connectionID); err != nil {
+			log.Println("failed to disconnect a user:", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(handler.config.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	var sequence uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case envelope := <-s.envelopes:
+			sequence++
+			if err := writeEvent(w, envelope, sequence); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := writeHeartbeat(w); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,21 @@
+package sse
+
+import "time"
+
+const DefaultHeartbeatInterval = 15 * time.Second
+
+type config struct {
+	heartbeatInterval time.Duration
+}
+
+type Option func(*config)
+
+// WithHeartbeatInterval sets how often an idle stream gets a comment line, so proxies keep it open.
+// Non-positive intervals keep the default.
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(c *config) {
+		if interval > 0 {
+			c.heartbeatInterval = interval
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sakura/impl/broadcaster"
+	"strconv"
+)
+
+// stream hands envelopes straight to the handler writing the response: the broadcaster already
+// queues them per connection and applies its overflow policy, so the stream buffers nothing.
+type stream struct {
+	id        string
+	envelopes chan broadcaster.Envelope
+	done      <-chan struct{}
+	cancel    context.CancelFunc
+}
+
+func (s *stream) ID() string {
+	return s.id
+}
+
+func (s *stream) Send(ctx context.Context, payload []byte) error {
+	return s.SendEnvelope(ctx, broadcaster.Envelope{Data: payload})
+}
+
+func (s *stream) SendEnvelope(ctx context.Context, envelope broadcaster.Envelope) error {
+	select {
+	case s.envelopes <- envelope:
+		return nil
+	case <-s.done:
+		return context.Canceled
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close ends the stream, the handler notices it and returns.
+func (s *stream) Close() error {
+	s.cancel()
+	return nil
+}
+
+// writeEvent uses message ids as event ids, so a reconnecting client reports
+// the last message it has seen in the Last-Event-ID header. Envelopes without a message id
+// are numbered with the stream's sequence instead, which lastSeen tells apart on reconnects.
+func writeEvent(w io.Writer, envelope broadcaster.Envelope, sequence uint64) error {
+	// JSON never contains raw newlines, so the envelope always fits into a single data field
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	id := envelope.MessageID
+	if id == "" {
+		id = strconv.FormatUint(sequence, 10)
+	}
+	_, err = fmt.Fprintf(w, "id: %s\ndata: %s\n\n", id, data)
+	return err
+}
+
+// lastSeen returns the message id a reconnecting client reports,
+// sequence numbers identify no message and come back empty.
+func lastSeen(lastEventID string) string {
+	if sequence, err := strconv.ParseUint(lastEventID, 10, 64); err == nil && strconv.FormatUint(sequence, 10) == lastEventID {
+		return ""
+	}
+	return lastEventID
+}
+
+func writeHeartbeat(w io.Writer) error {
+	_, err := io.WriteString(w, ": heartbeat\n\n")
+	return err
+}
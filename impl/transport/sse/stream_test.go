@@ -0,0 +1,53 @@
+package sse
+
+import (
+	"bytes"
+	"sakura/impl/broadcaster"
+	"strings"
+	"testing"
+)
+
+func TestWriteEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		envelope broadcaster.Envelope
+		sequence uint64
+		id       string
+	}{
+		{"message id", broadcaster.Envelope{MessageID: "0000000000000001ffffffffffffffff"}, 3, "0000000000000001ffffffffffffffff"},
+		{"sequence", broadcaster.Envelope{}, 3, "3"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := writeEvent(&buffer, test.envelope, test.sequence); err != nil {
+				t.Fatal(err)
+			}
+			if prefix := "id: " + test.id + "\ndata: {"; !strings.HasPrefix(buffer.String(), prefix) {
+				t.Fatalf("got %q, want it to start with %q", buffer.String(), prefix)
+			}
+			if !strings.HasSuffix(buffer.String(), "}\n\n") {
+				t.Fatalf("got %q, want a single data field", buffer.String())
+			}
+		})
+	}
+}
+
+func TestLastSeen(t *testing.T) {
+	tests := []struct {
+		lastEventID string
+		want        string
+	}{
+		{"", ""},
+		{"42", ""},
+		{"0000000000000001ffffffffffffffff", "0000000000000001ffffffffffffffff"},
+		{"00000000000000010000000000000002", "00000000000000010000000000000002"},
+	}
+	for _, test := range tests {
+		t.Run(test.lastEventID, func(t *testing.T) {
+			if got := lastSeen(test.lastEventID); got != test.want {
+				t.Fatalf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
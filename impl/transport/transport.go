@@ -0,0 +1,7 @@
+package transport
+
+import "net/http"
+
+// Authenticator resolves the user behind the request,
+// an error makes the transport reject the request with 401.
+type Authenticator func(r *http.Request) (string, error)
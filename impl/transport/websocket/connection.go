@@ -0,0 +1,49 @@
+package websocket
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"sakura/impl/broadcaster"
+	"sync"
+	"time"
+)
+
+type connection struct {
+	id     string
+	conn   *websocket.Conn
+	config config
+	mu     sync.Mutex
+}
+
+func (c *connection) ID() string {
+	return c.id
+}
+
+func (c *connection) Send(ctx context.Context, payload []byte) error {
+	return c.SendEnvelope(ctx, broadcaster.Envelope{Data: payload})
+}
+
+func (c *connection) SendEnvelope(ctx context.Context, envelope broadcaster.Envelope) error {
+	return c.write(ServerFrame{
+		Type:    MessageFrame,
+		Message: &envelope,
+	})
+}
+
+func (c *connection) Close() error {
+	return c.conn.Close()
+}
+
+func (c *connection) write(frame ServerFrame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.config.writeWait)); err != nil {
+		return err
+	}
+	return c.conn.WriteJSON(frame)
+}
+
+func (c *connection) ping() error {
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.config.writeWait))
+}
@@ -0,0 +1,33 @@
+package websocket
+
+import "sakura/impl/broadcaster"
+
+const (
+	SubscribeFrame   = "subscribe"
+	UnsubscribeFrame = "unsubscribe"
+	PublishFrame     = "publish"
+	// ConfirmFrame acks the delivery of reliable messages, see broadcaster.Envelope.
+	ConfirmFrame = "confirm"
+
+	MessageFrame = "message"
+	AckFrame     = "ack"
+	ErrorFrame   = "error"
+)
+
+// ClientFrame is sent by clients, ID is echoed back in the corresponding ack or error frame.
+type ClientFrame struct {
+	ID          string            `json:"id,omitempty"`
+	Type        string            `json:"type"`
+	Topic       string            `json:"topic,omitempty"`
+	Data        []byte            `json:"data,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	MessageIDs  []string          `json:"message_ids,omitempty"`
+}
+
+type ServerFrame struct {
+	ID      string                `json:"id,omitempty"`
+	Type    string                `json:"type"`
+	Error   string                `json:"error,omitempty"`
+	Message *broadcaster.Envelope `json:"message,omitempty"`
+}
@@ -0,0 +1,164 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"sakura/core/event"
+	"sakura/impl/broadcaster"
+	"sakura/impl/transport"
+	"time"
+)
+
+// LastSeenParam is the query parameter carrying the id of the last message
+// a reconnecting client has seen, messages published after it are replayed.
+const LastSeenParam = "last_seen"
+
+type Handler struct {
+	broadcaster  *broadcaster.Broadcaster
+	authenticate transport.Authenticator
+	config       config
+}
+
+func New(broadcaster *broadcaster.Broadcaster, authenticate transport.Authenticator, opts ...Option) *Handler {
+	cfg := config{
+		pingInterval:   DefaultPingInterval,
+		pongWait:       DefaultPongWait,
+		writeWait:      DefaultWriteWait,
+		maxMessageSize: DefaultMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Handler{
+		broadcaster:  broadcaster,
+		authenticate: authenticate,
+		config:       cfg,
+	}
+}
+
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := handler.authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := handler.config.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied with an error
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	c := &connection{
+		id:     userID,
+		conn:   conn,
+		config: handler.config,
+	}
+
+	connectionID, err := handler.broadcaster.Connect(ctx, c, broadcaster.WithLastSeen(r.URL.Query().Get(LastSeenParam)))
+	if err != nil {
+		log.Println("failed to connect a user:", err)
+		_ = conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "failed to connect"),
+			time.Now().Add(handler.config.writeWait),
+		)
+		return
+	}
+	defer func() {
+		if err := handler.broadcaster.Disconnect(context.Background(), connectionID); err != nil {
+			log.Println("failed to disconnect a user:", err)
+		}
+	}()
+
+	go handler.keepalive(ctx, c)
+
+	if err := handler.read(ctx, c, connectionID); err != nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		log.Println("websocket connection failed:", err)
+	}
+}
+
+func (handler *Handler) keepalive(ctx context.Context, c *connection) {
+	ticker := time.NewTicker(handler.config.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.ping(); err != nil {
+				// a broken connection makes the read loop fail too
+				return
+			}
+		}
+	}
+}
+
+func (handler *Handler) read(ctx context.Context, c *connection, connectionID string) error {
+	c.conn.SetReadLimit(handler.config.maxMessageSize)
+	extend := func() error {
+		return c.conn.SetReadDeadline(time.Now().Add(handler.config.pongWait))
+	}
+	if err := extend(); err != nil {
+		return err
+	}
+	c.conn.SetPongHandler(func(string) error { return extend() })
+
+	for {
+		_, payload, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := extend(); err != nil {
+			return err
+		}
+
+		var frame ClientFrame
+		if err := json.Unmarshal(payload, &frame); err != nil {
+			if err := c.write(ServerFrame{Type: ErrorFrame, Error: "malformed frame"}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		reply := ServerFrame{ID: frame.ID, Type: AckFrame}
+		if err := handler.handle(ctx, c.id, connectionID, frame); err != nil {
+			reply.Type, reply.Error = ErrorFrame, err.Error()
+		}
+		if err := c.write(reply); err != nil {
+			return err
+		}
+	}
+}
+
+func (handler *Handler) handle(ctx context.Context, userID, connectionID string, frame ClientFrame) error {
+	s := handler.broadcaster.Sakura()
+
+	switch frame.Type {
+	case SubscribeFrame:
+		return s.User(userID).Subscribe(ctx, frame.Topic)
+	case UnsubscribeFrame:
+		return s.User(userID).Unsubscribe(ctx, frame.Topic)
+	case PublishFrame:
+		options := []event.Option{event.WithPublisher(userID), event.WithContentType(frame.ContentType)}
+		for key, value := range frame.Headers {
+			options = append(options, event.WithHeader(key, value))
+		}
+		return s.Topic(frame.Topic).Publish(ctx, frame.Data, options...)
+	case ConfirmFrame:
+		handler.broadcaster.Ack(connectionID, frame.MessageIDs...)
+		return nil
+	default:
+		return fmt.Errorf("unknown frame type: %q", frame.Type)
+	}
+}
@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"github.com/gorilla/websocket"
+	"time"
+)
+
+const (
+	DefaultPingInterval   = 30 * time.Second
+	DefaultPongWait       = 60 * time.Second
+	DefaultWriteWait      = 10 * time.Second
+	DefaultMaxMessageSize = 64 * 1024
+)
+
+type config struct {
+	upgrader       websocket.Upgrader
+	pingInterval   time.Duration
+	pongWait       time.Duration
+	writeWait      time.Duration
+	maxMessageSize int64
+}
+
+type Option func(*config)
+
+func WithUpgrader(upgrader websocket.Upgrader) Option {
+	return func(c *config) {
+		c.upgrader = upgrader
+	}
+}
+
+// WithKeepalive sets how often pings are sent and how long to wait for any frame
+// from the client before the connection is considered dead. The pong wait must be longer
+// than the ping interval, invalid or non-positive pairs keep the defaults.
+func WithKeepalive(pingInterval, pongWait time.Duration) Option {
+	return func(c *config) {
+		if pingInterval > 0 && pongWait > pingInterval {
+			c.pingInterval = pingInterval
+			c.pongWait = pongWait
+		}
+	}
+}
+
+// WithWriteWait sets how long a single frame may take to write, non-positive timeouts keep the default.
+func WithWriteWait(timeout time.Duration) Option {
+	return func(c *config) {
+		if timeout > 0 {
+			c.writeWait = timeout
+		}
+	}
+}
+
+func WithMaxMessageSize(size int64) Option {
+	return func(c *config) {
+		c.maxMessageSize = size
+	}
+}
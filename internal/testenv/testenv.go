@@ -0,0 +1,24 @@
+package testenv
+
+import (
+	"os"
+	"testing"
+)
+
+// Variables holding the data source names of databases to test against,
+// tests that need a database are skipped without its variable. They may drop Sakura's tables.
+const (
+	PostgresDSN = "SAKURA_POSTGRES_DSN"
+	MySQLDSN    = "SAKURA_MYSQL_DSN"
+)
+
+// DSN returns the data source name held by the variable, the test is skipped if it's not set.
+func DSN(t *testing.T, variable string) string {
+	t.Helper()
+
+	dsn := os.Getenv(variable)
+	if dsn == "" {
+		t.Skipf("%s is not set", variable)
+	}
+	return dsn
+}
@@ -1,6 +1,9 @@
 package sakura
 
-import "context"
+import (
+	"context"
+	"sakura/core/event"
+)
 
 type Plugin interface{}
 
@@ -8,12 +11,22 @@ type PluginInitializer interface {
 	Initialize(ctx context.Context, sakura *Sakura) error
 }
 
+// PluginBeforePublish may modify the event (e.g. add headers) before it's published.
 type PluginBeforePublish interface {
-	BeforePublish(ctx context.Context, sakura *Sakura, topic string, data []byte) error
+	BeforePublish(ctx context.Context, sakura *Sakura, topic string, ev *event.Event) error
 }
 
 type PluginAfterPublish interface {
-	AfterPublish(ctx context.Context, sakura *Sakura, topic string, data []byte)
+	AfterPublish(ctx context.Context, sakura *Sakura, topic string, ev event.Event)
+}
+
+// PluginBeforeSend may modify a direct message before it's sent to the user.
+type PluginBeforeSend interface {
+	BeforeSend(ctx context.Context, sakura *Sakura, user string, ev *event.Event) error
+}
+
+type PluginAfterSend interface {
+	AfterSend(ctx context.Context, sakura *Sakura, user string, ev event.Event)
 }
 
 type PluginBeforeSubscribe interface {
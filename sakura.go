@@ -4,6 +4,8 @@ import (
 	"context"
 	"sakura/core/broker"
 	"sakura/core/event"
+	"sakura/core/history"
+	"sakura/core/presence"
 	"sakura/core/subscription"
 )
 
@@ -13,18 +15,28 @@ type PubSub broker.PubSub[event.Event]
 type Builder struct {
 	Subscriptions subscription.Storage
 	Broker        Broker
+	// History is optional, without it topics keep no message history.
+	History history.History
+	// Presence is optional, without it connected users aren't tracked.
+	Presence presence.Store
+	// Middlewares wrap the broker, the first one is the outermost (see broker.Chain).
+	Middlewares []broker.Middleware[event.Event]
 }
 
 func (builder Builder) Build() *Sakura {
 	return &Sakura{
 		subscriptions: builder.Subscriptions,
-		broker:        builder.Broker,
+		broker:        broker.Chain[event.Event](builder.Broker, builder.Middlewares...),
+		history:       builder.History,
+		presence:      builder.Presence,
 	}
 }
 
 type Sakura struct {
 	subscriptions subscription.Storage
 	broker        Broker
+	history       history.History
+	presence      presence.Store
 	plugins       []Plugin
 }
 
@@ -62,6 +74,11 @@ func (sakura *Sakura) Broker() broker.Broker[event.Event] {
 	return sakura.broker
 }
 
+// Presence returns nil if presence tracking is disabled.
+func (sakura *Sakura) Presence() presence.Store {
+	return sakura.presence
+}
+
 func (sakura *Sakura) callPlugins(ctx context.Context, caller func(ctx context.Context, plugin Plugin) error) error {
 	if caller == nil {
 		return nil
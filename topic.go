@@ -2,6 +2,7 @@ package sakura
 
 import (
 	"context"
+	"errors"
 	"sakura/channels"
 	"sakura/common/util"
 	"sakura/core/event"
@@ -9,6 +10,13 @@ import (
 	"strings"
 )
 
+var (
+	ErrHistoryDisabled  = errors.New("history is disabled")
+	ErrPresenceDisabled = errors.New("presence is disabled")
+	ErrPatternPublish   = errors.New("can't publish to a topic pattern")
+	ErrInvalidPattern   = errors.New("'>' may only be the last token of a topic pattern")
+)
+
 func ChannelToTopicID(channel string) string {
 	return strings.TrimPrefix(channel, "topic/")
 }
@@ -17,7 +25,12 @@ type AbstractTopic interface {
 	ID() string
 	Subscribers(ctx context.Context) ([]string, error)
 	Drop(ctx context.Context) error
-	Publish(ctx context.Context, data []byte) error
+	Publish(ctx context.Context, data []byte, options ...event.Option) error
+	History(ctx context.Context, since string, limit int) ([]event.Event, error)
+	// Presence returns the subscribers that are currently connected.
+	// Only direct subscribers are counted, users subscribed through a pattern aren't
+	// (like join and leave events, which aren't published for them either).
+	Presence(ctx context.Context) ([]string, error)
 	Channel() string
 }
 
@@ -60,8 +73,40 @@ func (topic Topic) Drop(ctx context.Context) error {
 	return topic.pushEvent(ctx, TopicErasureEvent, nil)
 }
 
-func (topic Topic) Publish(ctx context.Context, data []byte) error {
-	return topic.pushEvent(ctx, PublishEvent, data)
+func (topic Topic) Publish(ctx context.Context, data []byte, options ...event.Option) error {
+	if channels.IsPattern(topic.id) {
+		return ErrPatternPublish
+	}
+
+	ev := event.New(PublishEvent, data, options...)
+
+	// the event is recorded first, so that whoever receives it may already find it in the history
+	if topic.sakura.history != nil {
+		if err := topic.sakura.history.Append(ctx, topic.id, ev); err != nil {
+			return err
+		}
+	}
+
+	return topic.sakura.Broker().Push(ctx, topic.Channel(), ev)
+}
+
+func (topic Topic) History(ctx context.Context, since string, limit int) ([]event.Event, error) {
+	if topic.sakura.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return topic.sakura.history.Range(ctx, topic.id, since, limit)
+}
+
+func (topic Topic) Presence(ctx context.Context) ([]string, error) {
+	if topic.sakura.presence == nil {
+		return nil, ErrPresenceDisabled
+	}
+
+	subscribers, err := topic.Subscribers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return topic.sakura.presence.Online(ctx, subscribers...)
 }
 
 func (topic Topic) Channel() string {
@@ -115,10 +160,13 @@ func (topic PluginTopic) Drop(ctx context.Context) error {
 	return nil
 }
 
-func (topic PluginTopic) Publish(ctx context.Context, data []byte) error {
+func (topic PluginTopic) Publish(ctx context.Context, data []byte, options ...event.Option) error {
+	// the event is built upfront so that plugins see (and may change) the exact metadata being published
+	ev := event.New(PublishEvent, data, options...)
+
 	err := topic.sakura.callPlugins(ctx, func(ctx context.Context, plugin Plugin) error {
 		if p, ok := plugin.(PluginBeforePublish); ok {
-			if err := p.BeforePublish(ctx, topic.sakura, topic.ID(), data); err != nil {
+			if err := p.BeforePublish(ctx, topic.sakura, topic.ID(), &ev); err != nil {
 				return err
 			}
 		}
@@ -128,14 +176,14 @@ func (topic PluginTopic) Publish(ctx context.Context, data []byte) error {
 		return err
 	}
 
-	err = topic.base.Publish(ctx, data)
+	err = topic.base.Publish(ctx, ev.Data, event.WithMetadata(ev.Metadata))
 	if err != nil {
 		return err
 	}
 
 	err = topic.sakura.callPlugins(ctx, func(ctx context.Context, plugin Plugin) error {
 		if p, ok := plugin.(PluginAfterPublish); ok {
-			p.AfterPublish(ctx, topic.sakura, topic.ID(), data)
+			p.AfterPublish(ctx, topic.sakura, topic.ID(), ev)
 		}
 		return nil
 	})
@@ -146,6 +194,14 @@ func (topic PluginTopic) Publish(ctx context.Context, data []byte) error {
 	return nil
 }
 
+func (topic PluginTopic) History(ctx context.Context, since string, limit int) ([]event.Event, error) {
+	return topic.base.History(ctx, since, limit)
+}
+
+func (topic PluginTopic) Presence(ctx context.Context) ([]string, error) {
+	return topic.base.Presence(ctx)
+}
+
 func (topic PluginTopic) Channel() string {
 	return topic.base.Channel()
 }
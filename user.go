@@ -15,6 +15,9 @@ type AbstractUser interface {
 	Unsubscribe(ctx context.Context, topic string) error
 	Drop(ctx context.Context) error
 	Subscriptions(ctx context.Context) ([]string, error)
+	Online(ctx context.Context) (bool, error)
+	// Send delivers a direct message to every connection of the user.
+	Send(ctx context.Context, data []byte, options ...event.Option) error
 	Channel() string
 }
 
@@ -28,6 +31,10 @@ func (user User) ID() string {
 }
 
 func (user User) Subscribe(ctx context.Context, topic string) error {
+	if !channels.ValidPattern(topic) {
+		return ErrInvalidPattern
+	}
+
 	err := user.sakura.subscriptions.Insert(ctx, subscription2.Subscription{
 		User:  user.id,
 		Topic: topic,
@@ -88,6 +95,22 @@ func (user User) Subscriptions(ctx context.Context) ([]string, error) {
 	return topics, nil
 }
 
+func (user User) Online(ctx context.Context) (bool, error) {
+	if user.sakura.presence == nil {
+		return false, ErrPresenceDisabled
+	}
+
+	online, err := user.sakura.presence.Online(ctx, user.id)
+	if err != nil {
+		return false, err
+	}
+	return len(online) > 0, nil
+}
+
+func (user User) Send(ctx context.Context, data []byte, options ...event.Option) error {
+	return user.sakura.Broker().Push(ctx, user.Channel(), event.New(DirectMessageEvent, data, options...))
+}
+
 func (user User) Channel() string {
 	return channels.FromUser(user.id)
 }
@@ -202,6 +225,44 @@ func (user PluginUser) Subscriptions(ctx context.Context) ([]string, error) {
 	return user.base.Subscriptions(ctx)
 }
 
+func (user PluginUser) Online(ctx context.Context) (bool, error) {
+	return user.base.Online(ctx)
+}
+
+func (user PluginUser) Send(ctx context.Context, data []byte, options ...event.Option) error {
+	// the event is built upfront so that plugins see (and may change) the exact metadata being sent
+	ev := event.New(DirectMessageEvent, data, options...)
+
+	err := user.sakura.callPlugins(ctx, func(ctx context.Context, plugin Plugin) error {
+		if p, ok := plugin.(PluginBeforeSend); ok {
+			if err := p.BeforeSend(ctx, user.sakura, user.ID(), &ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = user.base.Send(ctx, ev.Data, event.WithMetadata(ev.Metadata))
+	if err != nil {
+		return err
+	}
+
+	err = user.sakura.callPlugins(ctx, func(ctx context.Context, plugin Plugin) error {
+		if p, ok := plugin.(PluginAfterSend); ok {
+			p.AfterSend(ctx, user.sakura, user.ID(), ev)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (user PluginUser) Channel() string {
 	return user.base.Channel()
 }